@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/doctor"
+	"send2slack/internal/sender"
+	"send2slack/internal/setup"
+)
+
+type configParams struct {
+	server   bool // use server.yaml instead of client.yaml
+	force    bool // overwrite an existing file
+	defaults bool // write the default values without asking
+}
+
+// newConfigCmd returns the config subcommand grouping the commands handling configuration files
+func newConfigCmd(params *cmdParams) *cobra.Command {
+
+	cfgParams := configParams{}
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Validate and create configuration files",
+		Long: `Validate and create the configuration files, client.yaml is used by the cli and server.yaml by the daemon.
+Without --config the files are searched in ./, $HOME/.send2slack/ and /etc/send2slack/.`,
+	}
+	cmd.PersistentFlags().BoolVar(&cfgParams.server, "server", false, "use the server configuration server.yaml")
+
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Load the configuration and report errors",
+		Long: `Load the configuration and report unknown keys, invalid listen urls, missing files and the permissions of
+the watched directories. "send2slack doctor" also verifies the token and the channels with the slack api.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configValidate(*params, cfgParams)
+		},
+	}
+
+	init := &cobra.Command{
+		Use:   "init",
+		Short: "Create a configuration file",
+		Long: `Create a commented configuration file, client.yaml or with --server server.yaml, in /etc/send2slack when run
+as root or in $HOME/.send2slack, or the file set with --config. On a terminal the token, the channels and for the
+server the listen address and the mbox directory are asked for and the token is verified with the slack api.
+
+Files containing a token are only readable by the owner (0600), the server configuration is owned by the
+service user "send2slack" if it exists. Existing files are only replaced with --force.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configInit(*params, cfgParams)
+		},
+	}
+	init.Flags().BoolVar(&cfgParams.force, "force", false, "overwrite an existing configuration file")
+	init.Flags().BoolVar(&cfgParams.defaults, "defaults", false, "write the default values without asking")
+
+	cmd.AddCommand(validate, init)
+	return cmd
+}
+
+// newDoctorCmd returns the doctor subcommand, it validates the configuration and the connection to slack
+func newDoctorCmd(params *cmdParams) *cobra.Command {
+
+	server := false
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the configuration, the token and the channels",
+		Long: `Run the checks of "send2slack config validate" and verify the configuration with the slack api: the token
+with auth.test and the existence of every configured channel and user. In client mode and in relay mode the
+send2slack server has to be reachable instead. Exits with 1 if problems were found.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			checkConfig(*params, server, true)
+		},
+	}
+	cmd.Flags().BoolVar(&server, "server", false, "check the server configuration server.yaml")
+	return cmd
+}
+
+// configValidate checks the configuration without the slack api and exits with an error on problems
+func configValidate(params cmdParams, cfgParams configParams) {
+	checkConfig(params, cfgParams.server, false)
+}
+
+// checkConfig loads the client or server configuration, prints the report of the checks and exits with an
+// error if problems were found
+func checkConfig(params cmdParams, server bool, online bool) {
+
+	var report *doctor.Report
+	if server {
+		cfg, err := newLoader(params).Daemon()
+		if err != nil {
+			HandleErr(fmt.Errorf("invalid configuration: %v", err))
+		}
+		report = doctor.Daemon(cfg, cfg.File, online)
+	} else {
+		cfg, err := newLoader(params).Client()
+		if err != nil {
+			HandleErr(fmt.Errorf("invalid configuration: %v", err))
+		}
+		report = doctor.Client(cfg, cfg.File, online)
+	}
+
+	report.Print(os.Stdout)
+	if report.Problems() > 0 {
+		os.Exit(1)
+	}
+}
+
+// configInit writes a new configuration file, on a terminal the values are asked for and the token is verified
+func configInit(params cmdParams, cfgParams configParams) {
+
+	path := params.configFile
+	if path == "" {
+		var err error
+		path, err = setup.DefaultPath(cfgParams.server)
+		HandleErr(err)
+	}
+
+	if _, err := os.Stat(path); err == nil && !cfgParams.force {
+		HandleErr(fmt.Errorf("%s already exists, use --force to overwrite it", path))
+	}
+
+	answers := setup.DefaultAnswers()
+	if !cfgParams.defaults && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("creating %s, press enter to use the value in brackets\n", path)
+		wizard := setup.NewWizard(os.Stdin, os.Stdout)
+
+		var err error
+		answers, err = wizard.Prompt(cfgParams.server)
+		HandleErr(err)
+
+		if answers.Token != "" {
+			sndr, err := sender.NewSlackSender(&config.ClientConfig{Mode: config.ModeDirectCli, Token: answers.Token})
+			HandleErr(err)
+			res, err := sndr.TestConnection()
+			if err == nil {
+				fmt.Println(res)
+			} else if !wizard.Confirm(fmt.Sprintf("%v, write the configuration anyway?", err)) {
+				fmt.Println("nothing written")
+				return
+			}
+		}
+	}
+
+	content, err := setup.Render(cfgParams.server, answers)
+	HandleErr(err)
+
+	owner := ""
+	if cfgParams.server {
+		owner = setup.ServiceUser
+	}
+	err = setup.Write(path, content, answers.Token != "", owner)
+	HandleErr(err)
+	fmt.Printf("configuration written to %s\n", path)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+)
+
+// newDaemonCmd returns the daemon subcommand, it runs the server, the mbox watcher and the other inputs
+func newDaemonCmd(params *cmdParams) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the server, the mbox watcher and the other configured inputs",
+		Long: `Run the daemon using server.yaml, all the inputs enabled in the configuration are started: the http server
+(daemon.listen_url), the mbox watcher (daemon.mbox_watch), the syslog receiver, the log watcher and the smtp
+listener. With --server or --watch only the selected one of the http server and the mbox watcher is started.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			send2SlackDaemon(*params)
+		},
+	}
+	cmd.Flags().BoolVarP(&params.server, "server", "s", false, "start the http server")
+	cmd.Flags().BoolVarP(&params.watcher, "watch", "w", false, "start the mbox watcher")
+	return cmd
+}
+
+// getSend2SlackDaemonConfig uses the cli parameters to create a daemon config
+func getSend2SlackDaemonConfig(params cmdParams) *config.DaemonConfig {
+
+	if params.configFile == "" {
+		cfgFile := " /etc/send2slack/server.yaml | $HOME/.send2slack/server.yaml | ./server.yaml "
+		logrus.Infof("loading server configuration file from: %s", cfgFile)
+	} else {
+		logrus.Infof("loading server configuration file: \"%s\"", params.configFile)
+	}
+
+	cfg, err := newLoader(params).Daemon()
+	HandleErr(err)
+
+	if cfg.IsDefault {
+		logrus.Infof("configuration file not found, using default values")
+	} else {
+		logrus.Infof("using configuration file: %s", cfg.File)
+	}
+	for _, f := range cfg.DropIns {
+		logrus.Infof("merged configuration file: %s", f)
+	}
+
+	// with --server or --watch only the selected one of the http server and the mbox watcher is started
+	if params.server || params.watcher {
+		if !params.watcher {
+			cfg.WatchDir = "false"
+		}
+		if !params.server {
+			cfg.ListenUrl = "false"
+		}
+	}
+
+	return cfg
+}
+
+// send2SlackDaemon starts the daemon and blocks until it is stopped
+func send2SlackDaemon(params cmdParams) {
+	// start in server mode
+	daemonCfg := getSend2SlackDaemonConfig(params)
+
+	dmn, err := daemon.NewDaemon(daemonCfg)
+	HandleErr(err)
+	dmn.Start()
+}
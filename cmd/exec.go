@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"send2slack/internal/wrapper"
+	"strconv"
+	"time"
+)
+
+type execParams struct {
+	onFailure bool // only notify if the command fails
+	tail      int  // amount of output lines added to the message
+	started   bool // post a message when the command starts, updated when it finishes
+}
+
+// newExecCmd returns the exec subcommand, it runs a command and reports the exit status and output
+func newExecCmd(params *cmdParams) *cobra.Command {
+
+	execParams := execParams{}
+
+	cmd := &cobra.Command{
+		Use:   "exec [flags] -- command [args]",
+		Short: "Run a command and send its exit status and output",
+		Long: `Run a command and send a message with the exit code, the duration and the last lines of the output,
+successful runs are sent in green, failed ones in red. send2slack exits with the exit code of the command.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(send2SlackExec(args, *params, execParams))
+		},
+	}
+	// flags after the command belong to the command
+	cmd.Flags().SetInterspersed(false)
+
+	addClientFlags(cmd.Flags(), params)
+
+	cmd.Flags().BoolVar(&execParams.onFailure, "on-failure", false, "only send a message if the command fails")
+	cmd.Flags().IntVar(&execParams.tail, "tail", 20, "amount of output lines added to the message, 0 to disable")
+	cmd.Flags().BoolVar(&execParams.started, "started", false, "send a message when the command starts, it is updated when the command finishes")
+
+	return cmd
+}
+
+// send2SlackExec runs the command and sends the result, returns the exit code of the command
+func send2SlackExec(args []string, params cmdParams, execParams execParams) int {
+
+	slackCfg := getSend2SlackClientConfig(params)
+	if params.channel == "" {
+		params.channel = slackCfg.DefChannel
+	}
+	slackSender := getCliSender(slackCfg, params)
+
+	host, _ := os.Hostname()
+	cmdLine := wrapper.CommandLine(args[0], args[1:])
+
+	// the started message is replaced with the result using the thread key
+	var threadKey string
+	if execParams.started {
+		threadKey = "exec:" + host + ":" + strconv.Itoa(os.Getpid()) + ":" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err := slackSender.SendMessage(&sender.Message{
+			Destination: params.channel,
+			Text:        fmt.Sprintf("`%s` started on %s", cmdLine, host),
+			Color:       "blue",
+			ThreadKey:   threadKey,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "send2slack: unable to send the started message: %v\n", config.RedactError(err))
+		}
+	}
+
+	res := wrapper.Run(args[0], args[1:], execParams.tail)
+	if res.Err != nil {
+		fmt.Fprintf(os.Stderr, "send2slack: %v\n", res.Err)
+	}
+
+	if res.ExitCode == 0 && execParams.onFailure && !execParams.started {
+		return res.ExitCode
+	}
+
+	msg := sender.Message{
+		Destination: params.channel,
+		Text:        execResultText(cmdLine, host, res),
+		Color:       "green",
+		ThreadKey:   threadKey,
+		Update:      threadKey != "",
+	}
+	if res.ExitCode != 0 {
+		msg.Color = "red"
+	}
+
+	err := slackSender.SendMessage(&msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "send2slack: unable to send the result: %v\n", config.RedactError(err))
+	}
+	return res.ExitCode
+}
+
+// execResultText composes the message text of a finished command
+func execResultText(cmdLine string, host string, res *wrapper.Result) string {
+
+	duration := res.Duration.Round(10 * time.Millisecond)
+	if duration > time.Minute {
+		duration = duration.Round(time.Second)
+	}
+
+	var text string
+	switch {
+	case res.Err != nil:
+		text = fmt.Sprintf("`%s` could not be started on %s: %v", cmdLine, host, res.Err)
+	case res.Signal != "":
+		text = fmt.Sprintf("`%s` was killed by %s on %s after %s", cmdLine, res.Signal, host, duration)
+	case res.ExitCode == 0:
+		text = fmt.Sprintf("`%s` succeeded on %s after %s", cmdLine, host, duration)
+	default:
+		text = fmt.Sprintf("`%s` failed with exit code %d on %s after %s", cmdLine, res.ExitCode, host, duration)
+	}
+
+	if res.Output != "" {
+		text += "\n```" + res.Output + "```"
+	}
+	return text
+}
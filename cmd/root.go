@@ -4,27 +4,35 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"net/url"
+	"github.com/spf13/pflag"
 	"os"
+	"path/filepath"
 	"send2slack/internal/config"
-	"send2slack/internal/daemon"
 	"send2slack/internal/sender"
 	"strconv"
-	"strings"
+	"time"
 )
 
 type cmdParams struct {
-	printExtHelp bool // print extended help
 	verbose      bool
 	version      bool
 	configFile   string
+	strict       bool
 	server       bool
 	watcher      bool
 	remote       string
 	localRemote  bool
 	channel      string
 	color        string
+	severity     string
+	file         string
+	snippet      bool
+	snippetType  string
+	title        string
+	stream       bool
+	streamThread bool
+	interval     time.Duration
+	maxInputSize int64
 }
 
 var (
@@ -33,115 +41,102 @@ var (
 	Date    = ""
 )
 
-// execute the normal mode command, NOT replacing sendmail
+// Run executes the cli, invoked as "sendmail" the input is handled as mail
 func Run() {
 
+	// the token must not show up in the logs
+	logrus.AddHook(config.RedactHook{})
+
+	if filepath.Base(os.Args[0]) == "sendmail" {
+		send2SlackSendmail(os.Args[1:])
+		return
+	}
+
 	params := cmdParams{}
+	printExtHelp := false
 
 	cmd := &cobra.Command{
 		Short: "Send messages to slack",
 		Long: ` == send2slack v` + Version + ` ==
-The send2slack behaves differently depending on configuration and invocation:
-- send2slack can be started as http server to receive json payloads that will be delivered to slack
-- TODO: can be started as file watcher, and all changes will be sent to slack ( /var/mail )
-- a cli that sends json payloads to the json server
-- a cli that (given the corresponding configuration) can send the messages directly without the server
-- is a sendmail binary replacement, it accepts input streams in mail format to be sent to slack
+send2slack sends messages to slack, directly with the slack token or through a send2slack server:
+- "send2slack send" sends a message, this is the default if no command is given
+- "send2slack exec" runs a command and sends its exit status and output
+- "send2slack daemon" starts the http server, the mbox watcher and the other configured inputs
+- "send2slack config" validates and creates configuration files
+- "send2slack doctor" checks the configuration, the token and the channels
+- "send2slack test-connection" verifies the connection to slack or to the send2slack server
+- invoked as "sendmail" (i.e. a symlink) it accepts mails on stdin and sends them to slack
 `,
-		Use: "send2slack (message)",
+		Use: "send2slack (message | -)",
 		Run: func(cmd *cobra.Command, args []string) {
 
+			// deprecated flags of the previous flag based cli
 			if params.version {
 				printVersion()
-			} else if params.printExtHelp {
-				extendedHelp()
+			} else if printExtHelp {
+				cmd.Help()
 			} else if params.server || params.watcher {
-				// start in server mode
 				send2SlackDaemon(params)
 			} else {
-				// use the client to send a message
-				send2SlackCli(cmd, args, params)
+				send2SlackCli(args, params)
 			}
-
-		},
-		FParseErrWhitelist: cobra.FParseErrWhitelist{
-			UnknownFlags: true,
 		},
+		Args: cobra.MaximumNArgs(1),
 	}
-	cmd.Flags().BoolVarP(&params.printExtHelp, "extended-help", "H", false, "print the extended help")
-	cmd.Flags().BoolVarP(&params.verbose, "verbose", "v", false, "verbose mode")
-	cmd.Flags().BoolVarP(&params.version, "version", "V", false, "print version")
+	cmd.PersistentFlags().BoolVarP(&params.verbose, "verbose", "v", false, "verbose mode")
+	cmd.PersistentFlags().StringVarP(&params.configFile, "config", "f", "", "config file")
+	cmd.PersistentFlags().BoolVar(&params.strict, "strict", false, "fail on unknown configuration keys and S2S_* env variables")
 
-	cmd.Flags().StringVarP(&params.configFile, "config", "f", "", "config file")
+	// without a command a message is sent
+	addClientFlags(cmd.Flags(), &params)
+	addSendFlags(cmd.Flags(), &params)
 
+	cmd.Flags().BoolVarP(&printExtHelp, "extended-help", "H", false, "print the extended help")
+	cmd.Flags().BoolVarP(&params.version, "version", "V", false, "print version")
 	cmd.Flags().BoolVarP(&params.server, "server", "s", false, "run in server mode")
 	cmd.Flags().BoolVarP(&params.watcher, "watch", "w", false, "run in mbox watcher mode")
+	cmd.Flags().MarkDeprecated("extended-help", "use \"send2slack help <command>\"")
+	cmd.Flags().MarkDeprecated("version", "use \"send2slack version\"")
+	cmd.Flags().MarkDeprecated("server", "use \"send2slack daemon --server\"")
+	cmd.Flags().MarkDeprecated("watch", "use \"send2slack daemon --watch\"")
+
+	cmd.AddCommand(newSendCmd(&params))
+	cmd.AddCommand(newExecCmd(&params))
+	cmd.AddCommand(newDaemonCmd(&params))
+	cmd.AddCommand(newConfigCmd(&params))
+	cmd.AddCommand(newDoctorCmd(&params))
+	cmd.AddCommand(newTestConnectionCmd(&params))
+	cmd.AddCommand(newVersionCmd())
 
-	cmd.Flags().StringVarP(&params.remote, "remote", "r", "", "send message to remote proxy server")
-	cmd.Flags().BoolVarP(&params.localRemote, "local-remote", "R", false, "same as \"remote\" but uses \"127.0.0.1:"+strconv.Itoa(config.DefaultPort)+"\" as destination")
-
-	cmd.Flags().StringVarP(&params.channel, "channel", "d", "", "destination channel to send the message")
-	cmd.Flags().StringVarP(&params.color, "color", "c", "", "color")
-
-	// normal cli mode
 	if err := cmd.Execute(); err != nil {
-		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func extendedHelp() {
-	fmt.Println("todo: Extended help")
-}
-
-func printVersion() {
-
-	v := `Version: ` + Version + `
-Commit id: ` + Commit + `
-Build date: ` + Date
-	fmt.Println(v)
+// addClientFlags adds the flags selecting how and where messages are sent
+func addClientFlags(flags *pflag.FlagSet, params *cmdParams) {
+	flags.StringVarP(&params.remote, "remote", "r", "", "send message to remote proxy server")
+	flags.BoolVarP(&params.localRemote, "local-remote", "R", false, "same as \"remote\" but uses \"127.0.0.1:"+strconv.Itoa(config.DefaultPort)+"\" as destination")
+	flags.StringVarP(&params.channel, "channel", "d", "", "destination channel to send the message")
 }
 
-// getSend2SlackDaemonConfig uses the cli parameters to create a daemon config
-func getSend2SlackDaemonConfig(params cmdParams) *config.DaemonConfig {
-
-	if params.configFile == "" {
-		cfgFile := " /etc/send2slack/server.yaml | $HOME/.send2slack/server.yaml | ./server.yaml "
-		logrus.Infof("loading server configuration file from: %s", cfgFile)
-	} else {
-		logrus.Infof("loading server configuration file: \"%s\"", params.configFile)
+// newLoader returns the configuration loader for the cli parameters, the client flags overwrite the values
+// of the configuration
+func newLoader(params cmdParams) *config.Loader {
+	overrides := map[string]interface{}{}
+	if params.localRemote {
+		overrides["client.remote_url"] = "http://127.0.0.1:" + strconv.Itoa(config.DefaultPort)
+	} else if params.remote != "" {
+		overrides["client.remote_url"] = params.remote
 	}
-
-	cfg, err := config.NewDaemonConfig(params.configFile)
-	HandleErr(err)
-
-	if cfg.IsDefault {
-		logrus.Infof("configuration file not found, using default values")
-	} else {
-		logrus.Infof("using configuration file: %s", viper.ConfigFileUsed())
+	if params.channel != "" {
+		overrides["slack.default_channel"] = params.channel
 	}
-
-	// disable watcher if not enabled with flag
-	if !params.watcher {
-		cfg.WatchDir = "false"
+	return &config.Loader{
+		File:      params.configFile,
+		Strict:    params.strict,
+		Overrides: overrides,
 	}
-
-	// disable server if not enabled with flag
-	if !params.server {
-		cfg.ListenUrl = "false"
-	}
-
-	return cfg
-}
-
-// send to slack command normally executed, see sendmail for exception
-func send2SlackDaemon(params cmdParams) {
-	// start in server mode
-	daemonCfg := getSend2SlackDaemonConfig(params)
-
-	dmn, err := daemon.NewDaemon(daemonCfg)
-	HandleErr(err)
-	dmn.Start()
 }
 
 // getSend2SlackConfig uses the cli parameters to create a send2slack config
@@ -155,103 +150,40 @@ func getSend2SlackClientConfig(params cmdParams) *config.ClientConfig {
 		}
 	}
 
-	slackCfg, err := config.NewClientConfig(params.configFile)
+	slackCfg, err := newLoader(params).Client()
 	HandleErr(err)
 
 	if params.verbose {
 		if slackCfg.IsDefault {
 			fmt.Printf("configuration file not found, using default values\n")
 		} else {
-			fmt.Printf("using configuration file: %s\n", viper.ConfigFileUsed())
+			fmt.Printf("using configuration file: %s\n", slackCfg.File)
 		}
 	}
 
 	return slackCfg
 }
 
-//// send to slack command normally executed, see sendmail for exception
-func send2SlackCli(cmd *cobra.Command, args []string, params cmdParams) {
-
-	slackCfg := getSend2SlackClientConfig(params)
-
-	var inText string
-	var err error
+// getCliSender creates the sender, either in direct mode or in client mode if a remote server is
+// configured or set with the flags
+func getCliSender(slackCfg *config.ClientConfig, params cmdParams) *sender.SlackSender {
 
-	if len(args) > 0 {
-		inText = args[0]
-	} else {
-		inText, err = sender.InReader(1000000) // 1MB
-		if err != nil && err.Error() != "io reader not started" {
-			HandleErr(err)
-		}
-	}
-
-	// set the default channel if none is provided
-	if params.channel == "" {
-		params.channel = slackCfg.DefChannel
-	}
-
-	msg := sender.Message{
-		Destination: params.channel,
-		Color:       params.color,
-		Text:        inText,
-	}
-
-	// ============================================
-	// the message has been composed, now we send it
-	// depending on the invocation, either in direct mode or in client mode.
-
-	if params.remote != "" || params.localRemote {
-
-		if params.localRemote {
-			params.remote = "http://127.0.0.1:" + strconv.Itoa(config.DefaultPort)
-		}
-
-		// prepend http:// if not provided already
-		if !strings.HasPrefix(params.remote, "http://") && !strings.HasPrefix(params.remote, "https://") {
-			params.remote = "http://" + params.remote
-		}
-
-		u, err := url.ParseRequestURI(params.remote)
-		if err != nil {
-			HandleErr(fmt.Errorf("invalid url: %v", err))
-		}
-
-		slackCfg.Mode = config.ModeHttpClient
-		slackCfg.Url = u
-
-		if params.verbose {
-			fmt.Printf("sending message in \"client mode\" to channel: \"%s\" using server: \"%s\" \n", params.channel, params.remote)
-		}
-
-	} else {
-		// if uri has been defined in configuration we ignore the direct cli fallback
-		if slackCfg.Url == nil {
-			if params.verbose {
-				fmt.Printf("sending message in \"direct mode\" to channel: \"%s\"\n", params.channel)
-			}
-			slackCfg.Mode = config.ModeDirectCli
+	if params.verbose {
+		if slackCfg.Mode == config.ModeHttpClient {
+			fmt.Printf("sending message in \"client mode\" to channel: \"%s\" using server: \"%s\" \n", params.channel, slackCfg.Url)
+		} else {
+			fmt.Printf("sending message in \"direct mode\" to channel: \"%s\"\n", params.channel)
 		}
-
 	}
 
 	slackSender, err := sender.NewSlackSender(slackCfg)
 	HandleErr(err)
-
-	err = slackSender.SendMessage(&msg)
-	HandleErr(err)
-
-	if err != nil && err.Error() == "unable to send empty message" {
-		fmt.Print("> Unable to send empty message \n\n")
-		cmd.Help()
-	} else {
-		HandleErr(err)
-	}
+	return slackSender
 }
 
 func HandleErr(err error) {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Fatal error: %v \n", err.Error())
+		fmt.Fprintf(os.Stderr, "Fatal error: %v \n", config.Redact(err.Error()))
 		os.Exit(1)
 	}
 }
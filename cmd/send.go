@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"os"
+	"send2slack/internal/compose"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"send2slack/internal/stream"
+	"strconv"
+	"time"
+)
+
+// newSendCmd returns the send subcommand, invoking send2slack without a command does the same
+func newSendCmd(params *cmdParams) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "send [flags] (message | -)",
+		Short: "Send a message",
+		Long: `Send a message to slack, directly with the slack token or through a send2slack server with --remote.
+
+The message is taken from the argument, or read from stdin if it is piped or redirected or the argument is "-".
+Run from a terminal without a message, the message is composed in $VISUAL or $EDITOR and sent after confirming
+the channel. --file uploads a file with the message as comment, --stream sends stdin while it is written.`,
+		Example: `  send2slack send -d ops -c red "disk full on web-03"
+  df -h | send2slack send -d ops --snippet --title "disk usage"
+  make 2>&1 | send2slack send --stream -d builds`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			send2SlackCli(args, *params)
+		},
+	}
+	addClientFlags(cmd.Flags(), params)
+	addSendFlags(cmd.Flags(), params)
+	return cmd
+}
+
+// addSendFlags adds the flags composing the message
+func addSendFlags(flags *pflag.FlagSet, params *cmdParams) {
+	flags.StringVarP(&params.color, "color", "c", "", "color")
+	flags.StringVarP(&params.severity, "severity", "S", "", "severity of the message: info, warning, error or critical")
+
+	flags.StringVar(&params.file, "file", "", "upload a file, the message is used as comment")
+	flags.BoolVar(&params.snippet, "snippet", false, "send the message, or the file, as collapsible text snippet")
+	flags.StringVar(&params.snippetType, "snippet-type", "", "syntax type of the snippet, i.e. diff, go or shell")
+	flags.StringVar(&params.title, "title", "", "title of the file or snippet")
+
+	flags.Int64Var(&params.maxInputSize, "max-input-size", sender.DefaultMaxInputSize, "maximum size in bytes of a message read from stdin")
+
+	flags.BoolVar(&params.stream, "stream", false, "send stdin while it is written, the message is updated with new lines")
+	flags.BoolVar(&params.streamThread, "stream-thread", false, "with --stream, post new lines as thread replies instead of updating the message")
+	flags.DurationVar(&params.interval, "stream-interval", 2*time.Second, "with --stream, minimum time between two messages")
+}
+
+// send2SlackCli sends the message given as argument, read from stdin or composed in the editor
+func send2SlackCli(args []string, params cmdParams) {
+
+	slackCfg := getSend2SlackClientConfig(params)
+
+	if params.stream {
+		send2SlackStream(slackCfg, params)
+		return
+	}
+
+	var inText string
+	var err error
+
+	// stdin is read if it is piped or redirected, or explicitly with "-"
+	if len(args) > 0 && args[0] != "-" {
+		inText = args[0]
+	} else if len(args) > 0 || sender.IsPiped(os.Stdin) {
+		inText, err = sender.InReader(os.Stdin, params.maxInputSize)
+		HandleErr(err)
+	}
+
+	// set the default channel if none is provided
+	if params.channel == "" {
+		params.channel = slackCfg.DefChannel
+	}
+
+	// without a message the message is composed in the editor when run from a terminal
+	interactive := len(args) == 0 && params.file == "" && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	if interactive {
+		inText, err = compose.Edit(compose.Editor(), compose.Template(params.channel))
+		HandleErr(err)
+		if inText == "" {
+			fmt.Println("empty message, nothing sent")
+			return
+		}
+	}
+
+	msg := sender.Message{
+		Destination: params.channel,
+		Color:       params.color,
+		Severity:    params.severity,
+		Text:        inText,
+	}
+
+	// with --file the message is the comment of the file, with only --snippet the message is sent as snippet
+	if params.file != "" {
+		msg.File, err = sender.ReadFile(params.file)
+		HandleErr(err)
+	} else if params.snippet {
+		msg.File = &sender.File{
+			Name:    "snippet.txt",
+			Content: []byte(inText),
+		}
+		msg.Text = ""
+	}
+	if msg.File != nil {
+		msg.File.Snippet = params.snippet
+		msg.File.Filetype = params.snippetType
+		msg.File.Title = params.title
+	}
+
+	// ============================================
+	// the message has been composed, now we send it
+	// depending on the invocation, either in direct mode or in client mode.
+	slackSender := getCliSender(slackCfg, params)
+
+	if interactive && !confirmMessage(slackSender, &msg) {
+		fmt.Println("nothing sent")
+		return
+	}
+
+	err = slackSender.SendMessage(&msg)
+	HandleErr(err)
+}
+
+// confirmMessage shows the message as it will be posted and asks to confirm the destination
+func confirmMessage(slackSender *sender.SlackSender, msg *sender.Message) bool {
+
+	destination, preview, err := slackSender.Preview(msg)
+	HandleErr(err)
+
+	fmt.Println("----------")
+	fmt.Print(preview)
+	fmt.Println("----------")
+	return compose.Confirm(os.Stdin, os.Stdout, fmt.Sprintf("send to \"%s\"?", destination))
+}
+
+// send2SlackStream sends stdin in batches of lines until the producer closes it
+func send2SlackStream(slackCfg *config.ClientConfig, params cmdParams) {
+
+	if params.channel == "" {
+		params.channel = slackCfg.DefChannel
+	}
+
+	host, _ := os.Hostname()
+	streamer := stream.Streamer{
+		MsgSender:   getCliSender(slackCfg, params),
+		Destination: params.channel,
+		Color:       params.color,
+		Thread:      params.streamThread,
+		Interval:    params.interval,
+		ThreadKey:   "stream:" + host + ":" + strconv.Itoa(os.Getpid()) + ":" + strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+	err := streamer.Stream(os.Stdin)
+	HandleErr(err)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+)
+
+// maximum size of a mail read from stdin
+const maxMailSize = 10 << 20
+
+// send2SlackSendmail handles the invocation as sendmail, i.e. by cron or mail(1), the mail is read from
+// stdin and sent to the channel of the X-Slack-Channel header or to slack.email_channel, the sendmail options
+// and recipients are accepted but ignored
+func send2SlackSendmail(args []string) {
+
+	slackCfg, err := config.NewClientConfig("")
+	HandleErr(err)
+
+	in, err := sender.InReader(os.Stdin, maxMailSize)
+	HandleErr(err)
+
+	msg, err := sender.NewMessageFromMailStr(in)
+	HandleErr(err)
+
+	if msg.Destination == "" {
+		msg.Destination = slackCfg.EmailChannel
+	}
+	if msg.Destination == "" {
+		msg.Destination = slackCfg.DefChannel
+	}
+
+	// mails are sent through the server if a remote url is configured
+	if slackCfg.Mode != config.ModeHttpClient {
+		slackCfg.Mode = config.ModeMailSending
+	}
+	slackSender, err := sender.NewSlackSender(slackCfg)
+	HandleErr(err)
+
+	err = slackSender.SendMessage(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sendmail: %v\n", config.RedactError(err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// newTestConnectionCmd returns the test-connection subcommand, it verifies the token or the server without
+// sending a message
+func newTestConnectionCmd(params *cmdParams) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "test-connection",
+		Short: "Verify the connection to slack or to the send2slack server",
+		Long: `Verify the configuration of the client without sending a message: in direct mode the token is checked with
+the slack api, in client mode the send2slack server has to be reachable.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			slackCfg := getSend2SlackClientConfig(*params)
+			res, err := getCliSender(slackCfg, *params).TestConnection()
+			HandleErr(err)
+			fmt.Println(res)
+		},
+	}
+	addClientFlags(cmd.Flags(), params)
+	return cmd
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printVersion()
+		},
+	}
+}
+
+func printVersion() {
+
+	v := `Version: ` + Version + `
+Commit id: ` + Commit + `
+Build date: ` + Date
+	fmt.Println(v)
+}
@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// lines starting with the prefix are removed from the composed message
+const commentPrefix = "#"
+
+// Editor returns the editor configured in $VISUAL or $EDITOR, vi if none is set
+func Editor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(env)); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// Template returns the initial content of the editor, the comment lines explain how the message is sent
+func Template(destination string) string {
+	return "\n" +
+		commentPrefix + " compose the message for \"" + destination + "\", lines starting with \"" + commentPrefix + "\" are ignored\n" +
+		commentPrefix + " an empty message aborts sending\n"
+}
+
+// Edit opens the editor with the template and returns the message without comment lines, the editor
+// command is run by the shell so that it can contain arguments, i.e. "code --wait"
+func Edit(editor string, template string) (string, error) {
+
+	f, err := ioutil.TempFile("", "send2slack-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(template)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("editor \"%s\" failed: %v", editor, err)
+	}
+
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return StripComments(string(content)), nil
+}
+
+// StripComments removes the comment lines and the surrounding blank lines
+func StripComments(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// Confirm asks the question and reads the answer, only "y" and "yes" confirm
+func Confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
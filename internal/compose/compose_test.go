@@ -0,0 +1,58 @@
+package compose_test
+
+import (
+	"bytes"
+	"send2slack/internal/compose"
+	"strings"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+
+	// the "editor" appends the message to the template
+	editor := `printf 'deploy done\n\n# a comment\nall hosts updated\n' >>`
+	got, err := compose.Edit(editor, compose.Template("ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "deploy done\n\nall hosts updated"
+	if got != expected {
+		t.Errorf("unexpected message, got \"%s\" expected \"%s\"", got, expected)
+	}
+
+	_, err = compose.Edit("exit 1;", "")
+	if err == nil {
+		t.Error("expected an error if the editor fails")
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	got := compose.StripComments(compose.Template("ops"))
+	if got != "" {
+		t.Errorf("expected the template to be an empty message, got \"%s\"", got)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tcs := []struct {
+		answer   string
+		expected bool
+	}{
+		{answer: "y\n", expected: true},
+		{answer: " Yes \n", expected: true},
+		{answer: "n\n", expected: false},
+		{answer: "\n", expected: false},
+		{answer: "", expected: false},
+	}
+
+	for _, tc := range tcs {
+		var out bytes.Buffer
+		got := compose.Confirm(strings.NewReader(tc.answer), &out, "send to \"ops\"?")
+		if got != tc.expected {
+			t.Errorf("unexpected result for answer %q, got %v", tc.answer, got)
+		}
+		if out.String() != "send to \"ops\"? [y/N] " {
+			t.Errorf("unexpected question: %s", out.String())
+		}
+	}
+}
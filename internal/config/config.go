@@ -1,12 +1,11 @@
 package config
 
 import (
-	"github.com/spf13/viper"
+	"fmt"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Mode int
@@ -18,143 +17,334 @@ const (
 )
 const DefaultPort = 4789
 
-func readConfigFile(cfgFile string) (bool, error) {
+// Severity defines how messages of a severity level are represented and routed
+type Severity struct {
+	Channel string // send messages of this severity to a different channel
+	Color   string
+	Emoji   string // prefix the message with an emoji, i.e. ":warning:"
+	Mention string // notify "here", "channel" or a user group id
+}
 
-	if cfgFile != "" {
-		absPath, err := filepath.Abs(cfgFile)
-		if err != nil {
-			return false, err
-		}
-		viper.AddConfigPath(filepath.Dir(absPath))
-		viper.SetConfigName(filepath.Base(absPath))
-	} else {
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("$HOME/.send2slack")
-		viper.AddConfigPath("/etc/send2slack/")
+// readSeverities reads the per severity settings from the configuration
+func readSeverities(src *source) (map[string]Severity, error) {
+	var severities map[string]Severity
+	err := src.UnmarshalKey("slack.severity", &severities)
+	if err != nil {
+		return nil, err
 	}
+	return severities, nil
+}
 
-	viper.SetConfigType("yaml")
+// Hook maps the json payload received on /hooks/<name> to a message using go templates
+type Hook struct {
+	Text    string
+	Channel string
+	Color   string
+}
 
-	viper.SetDefault("default_channel", "general")
-	viper.SetDefault("sendmail_channel", "general")
+// GitWebhook configures the github or gitlab webhook receiver
+type GitWebhook struct {
+	Secret  string            // the webhook secret (github) or secret token (gitlab)
+	Channel string            // default channel for all repositories
+	Repos   map[string]string // channel per repository, i.e. "org/repo": "backend"
+}
 
-	fileRead := true
-	err := viper.ReadInConfig() // Find and read the config file
-	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found; ignore error if desired
-			fileRead = false
-		} else {
-			return false, err
-		}
-	}
-	return fileRead, nil
+// Syslog configures the syslog receiver
+type Syslog struct {
+	Listen    string // udp://<ip>:<port>, tcp://<ip>:<port> or unix://<path>, empty to disable
+	RateLimit int    `mapstructure:"rate_limit"` // maximum messages sent per minute, 0 for no limit
+	Filters   []SyslogFilter
+}
+
+// SyslogFilter selects the syslog messages to forward, all the defined conditions have to match
+type SyslogFilter struct {
+	Facility string // i.e. auth, cron, local0
+	Severity string // minimum severity, i.e. warning also matches err, crit, alert and emerg
+	Program  string
+	Match    string // regular expression matched against the message text
+	Channel  string
+}
+
+// LogWatch configures a log file that is tailed for lines matching the patterns
+type LogWatch struct {
+	Path      string
+	Channel   string
+	Multiline string // regular expression matching continuation lines, i.e. ^\s to group stack traces
+	Patterns  []LogPattern
+}
+
+// LogPattern sends the log entries matching the regular expression
+type LogPattern struct {
+	Match    string
+	Channel  string // overwrites the channel of the log file
+	Color    string
+	Severity string
+	Cooldown time.Duration // minimum time between two messages of this pattern
+}
+
+// Smtp configures the smtp listener
+type Smtp struct {
+	Listen     string // <ip>:<port>, empty to disable
+	Username   string // if set, clients have to authenticate before sending mails
+	Password   string
+	Recipients map[string]string // maps recipient addresses or local parts to a slack destination, i.e. "ops": "#ops"
+}
+
+// Socket sets the ownership and permissions of the unix socket the server listens on
+type Socket struct {
+	Owner string // user name or uid
+	Group string // group name or gid
+	Mode  string // octal file mode, i.e. "0660"
+}
+
+// ServerTLS enables https on the server, the certificate is reloaded when the files change
+type ServerTLS struct {
+	Cert     string // certificate file in PEM format
+	Key      string // private key file in PEM format
+	ClientCA string `mapstructure:"client_ca"` // if set, clients need a certificate signed by this CA (mTLS)
+}
+
+// Relay forwards all messages to an upstream send2slack server instead of sending them to slack,
+// only the upstream server needs a slack token
+type Relay struct {
+	Url   string // upstream server, i.e. https://send2slack.example.com:4789, empty to disable
+	Spool string // directory keeping the messages while the upstream server is not reachable
+	TLS   ClientTLS
 }
 
 type DaemonConfig struct {
-	IsDefault       bool   // set to true if no configuration file could be loaded
-	ListenUrl       string // used by the server, listen address
-	WatchDir        string // used by the server, watch for mbox dir
+	IsDefault       bool     // set to true if no configuration file could be loaded
+	File            string   // configuration file used, empty if none was found
+	DropIns         []string // files of conf.d merged on top of the configuration file
+	ListenUrl       string   // used by the server, listen address, <ip>:<port> or unix://<path>
+	WatchDir        string   // used by the server, watch for mbox dir
 	Token           string
 	DefChannel      string
 	SendmailChannel string
+	FallbackChannel string // channel used when a message cannot be delivered
+	UploadOverflow  bool   // upload the complete text of truncated messages as file
 	MailThrottling  int
+	MboxUsers       map[string]string // maps local mbox files (unix users) to a slack destination
+	Severities      map[string]Severity
+	Hooks           map[string]Hook // generic webhooks by name
+	Github          GitWebhook
+	Gitlab          GitWebhook
+	Syslog          Syslog
+	LogWatch        []LogWatch
+	Smtp            Smtp
+	Socket          Socket
+	TLS             ServerTLS
+	Relay           Relay
 }
 
 func NewDaemonConfig(cfgFile string) (*DaemonConfig, error) {
+	l := Loader{File: cfgFile}
+	return l.Daemon()
+}
 
-	viper.SetConfigName("server.yaml")
+// Daemon loads the configuration of the daemon, server.yaml
+func (l *Loader) Daemon() (*DaemonConfig, error) {
 
-	fileRead, err := readConfigFile(cfgFile)
+	src, err := l.load("server.yaml", true)
 	if err != nil {
 		return nil, err
 	}
 
-	// overwrite configuration token if env "SLACK_TOKEN" is set
-	slackToken := viper.GetString("slack.token")
-	if envSlackToken := os.Getenv("SLACK_TOKEN"); envSlackToken != "" {
-		slackToken = envSlackToken
-	}
 	// if we loaded config from a file, we are not using the default values
-	defaultConfg := true
-	if fileRead {
-		defaultConfg = false
+	defaultConfg := src.file == ""
+	if defaultConfg {
+		src.SetDefault("daemon.listen_url", "127.0.0.1:"+strconv.Itoa(DefaultPort))
+		src.SetDefault("daemon.mbox_watch", "false")
 	}
 
-	listenUrl := viper.GetString("daemon.listen_url")
-	if defaultConfg {
-		listenUrl = "127.0.0.1:" + strconv.Itoa(DefaultPort)
+	listenUrl := src.GetString("daemon.listen_url")
+	watchDir := src.GetString("daemon.mbox_watch")
+
+	severities, err := readSeverities(src)
+	if err != nil {
+		return nil, err
 	}
 
-	watchDir := viper.GetString("daemon.mbox_watch")
-	if defaultConfg {
-		watchDir = "false"
+	var hooks map[string]Hook
+	err = src.UnmarshalKey("daemon.hooks", &hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	var github, gitlab GitWebhook
+	err = src.UnmarshalKey("daemon.github", &github)
+	if err != nil {
+		return nil, err
+	}
+	err = src.UnmarshalKey("daemon.gitlab", &gitlab)
+	if err != nil {
+		return nil, err
+	}
+
+	var syslog Syslog
+	err = src.UnmarshalKey("daemon.syslog", &syslog)
+	if err != nil {
+		return nil, err
+	}
+
+	var logWatch []LogWatch
+	err = src.UnmarshalKey("daemon.logwatch", &logWatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var smtp Smtp
+	err = src.UnmarshalKey("daemon.smtp", &smtp)
+	if err != nil {
+		return nil, err
+	}
+
+	var socket Socket
+	err = src.UnmarshalKey("daemon.socket", &socket)
+	if err != nil {
+		return nil, err
+	}
+
+	var serverTLS ServerTLS
+	err = src.UnmarshalKey("daemon.tls", &serverTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	var relay Relay
+	err = src.UnmarshalKey("daemon.relay", &relay)
+	if err != nil {
+		return nil, err
+	}
+
+	// relays forward the messages to the upstream server, the token is not needed and token_command is not run
+	var slackToken string
+	if relay.Url == "" {
+		slackToken, err = readToken(src)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	cfg := DaemonConfig{
 		IsDefault:       defaultConfg,
+		File:            src.file,
+		DropIns:         src.dropIns,
 		Token:           slackToken,
-		DefChannel:      viper.GetString("slack.default_channel"),
-		SendmailChannel: viper.GetString("slack.email_channel"),
+		DefChannel:      src.GetString("slack.default_channel"),
+		SendmailChannel: src.GetString("slack.email_channel"),
+		FallbackChannel: src.GetString("slack.fallback_channel"),
+		UploadOverflow:  src.GetBool("slack.upload_overflow"),
 		WatchDir:        watchDir,
 		ListenUrl:       listenUrl,
 		MailThrottling:  1000,
+		MboxUsers:       src.GetStringMapString("daemon.mbox_users"),
+		Severities:      severities,
+		Hooks:           hooks,
+		Github:          github,
+		Gitlab:          gitlab,
+		Syslog:          syslog,
+		LogWatch:        logWatch,
+		Smtp:            smtp,
+		Socket:          socket,
+		TLS:             serverTLS,
+		Relay:           relay,
 	}
 	return &cfg, nil
 }
 
+// ClientTLS configures the connection to a https send2slack server
+type ClientTLS struct {
+	CA   string // CA bundle used to verify the server certificate, the system roots are used if empty
+	Cert string // client certificate for servers requiring mTLS
+	Key  string
+}
+
+// ParseRemoteUrl parses the url of a send2slack server, http:// is assumed if the scheme is missing
+func ParseRemoteUrl(remoteUrl string) (*url.URL, error) {
+	if !strings.HasPrefix(remoteUrl, "http://") && !strings.HasPrefix(remoteUrl, "https://") &&
+		!strings.HasPrefix(remoteUrl, "unix://") {
+		remoteUrl = "http://" + remoteUrl
+	}
+	return url.ParseRequestURI(remoteUrl)
+}
+
 type ClientConfig struct {
-	IsDefault  bool // set to true if no configuration file could be loaded
-	Mode       Mode
-	Url        *url.URL
-	Token      string
-	DefChannel string
+	IsDefault       bool   // set to true if no configuration file could be loaded
+	File            string // configuration file used, empty if none was found
+	Mode            Mode
+	Url             *url.URL
+	Token           string
+	DefChannel      string
+	EmailChannel    string // channel of mails sent with the sendmail invocation
+	FallbackChannel string // channel used when a message cannot be delivered
+	ApiUrl          string // overwrites the slack api endpoint, only useful for testing
+	Severities      map[string]Severity
+	TLS             ClientTLS
+	UploadOverflow  bool // upload the complete text of truncated messages as file
 }
 
 func NewClientConfig(cfgFile string) (*ClientConfig, error) {
+	l := Loader{File: cfgFile}
+	return l.Client()
+}
 
-	viper.SetConfigName("client.yaml")
-	fileRead, err := readConfigFile(cfgFile)
+// Client loads the configuration of the cli, client.yaml
+func (l *Loader) Client() (*ClientConfig, error) {
 
+	src, err := l.load("client.yaml", false)
 	if err != nil {
 		return nil, err
 	}
-	// overwrite configuration token if env "SLACK_TOKEN" is set
-	slackToken := viper.GetString("slack.token")
-	if envSlackToken := os.Getenv("SLACK_TOKEN"); envSlackToken != "" {
-		slackToken = envSlackToken
-	}
 
 	// if we loaded config from a file, we are not using the default values
-	defaultConfg := true
-	if fileRead {
-		defaultConfg = false
-	}
+	defaultConfg := src.file == ""
 
 	mode := Mode(ModeDirectCli)
 	var u *url.URL
 
-	remoteUrl := viper.GetString("client.remote_url")
+	remoteUrl := src.GetString("client.remote_url")
 	if remoteUrl != "" && remoteUrl != "false" {
 
-		if !strings.HasPrefix(remoteUrl, "http://") && !strings.HasPrefix(remoteUrl, "https://") {
-			remoteUrl = "http://" + remoteUrl
+		u, err = ParseRemoteUrl(remoteUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote_url: %v", err)
 		}
+		mode = ModeHttpClient
+	}
 
-		u, err = url.ParseRequestURI(remoteUrl)
+	// the server sends the messages in client mode, the token is not needed and token_command is not run
+	var slackToken string
+	if mode != ModeHttpClient {
+		slackToken, err = readToken(src)
 		if err != nil {
-			u = nil
-			mode = ModeDirectCli
+			return nil, err
 		}
-		mode = ModeHttpClient
+	}
+
+	severities, err := readSeverities(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientTLS ClientTLS
+	err = src.UnmarshalKey("client.tls", &clientTLS)
+	if err != nil {
+		return nil, err
 	}
 
 	cfg := ClientConfig{
-		IsDefault:  defaultConfg,
-		Token:      slackToken,
-		DefChannel: viper.GetString("slack.default_channel"),
-		Url:        u,
-		Mode:       mode,
+		IsDefault:       defaultConfg,
+		File:            src.file,
+		Token:           slackToken,
+		DefChannel:      src.GetString("slack.default_channel"),
+		EmailChannel:    src.GetString("slack.email_channel"),
+		FallbackChannel: src.GetString("slack.fallback_channel"),
+		Url:             u,
+		Mode:            mode,
+		Severities:      severities,
+		TLS:             clientTLS,
+		UploadOverflow:  src.GetBool("slack.upload_overflow"),
 	}
 	return &cfg, nil
 }
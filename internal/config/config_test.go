@@ -2,8 +2,10 @@ package config_test
 
 import (
 	"github.com/google/go-cmp/cmp"
-	"github.com/spf13/viper"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"send2slack/internal/config"
 	"strings"
 	"testing"
@@ -22,6 +24,11 @@ func getUrl(s string) *url.URL {
 	return u
 }
 
+func absPath(s string) string {
+	p, _ := filepath.Abs(s)
+	return p
+}
+
 func TestNewClientConfig(t *testing.T) {
 
 	tcs := []configTc{
@@ -39,9 +46,9 @@ func TestNewClientConfig(t *testing.T) {
 			file: "sampledata/client.yaml",
 			ClientExpected: &config.ClientConfig{
 				IsDefault:  false,
+				File:       absPath("sampledata/client.yaml"),
 				DefChannel: "general",
-				Token:      "my_token",
-				Mode:       config.ModeHttpClient,
+				Mode:       config.ModeHttpClient, // the token is not read in client mode
 				Url:        getUrl("http://127.0.0.1:4789"),
 			},
 			expectedErr: "",
@@ -51,8 +58,6 @@ func TestNewClientConfig(t *testing.T) {
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
 
-			viper.Reset()
-
 			cfg, err := config.NewClientConfig(tc.file)
 
 			if tc.expectedErr != "" && err != nil {
@@ -88,6 +93,7 @@ func TestNewDaemonConfig(t *testing.T) {
 				ListenUrl:      "127.0.0.1:4789",
 				WatchDir:       "false",
 				MailThrottling: 1000,
+				MboxUsers:      map[string]string{},
 			},
 			expectedErr: "",
 		},
@@ -96,12 +102,30 @@ func TestNewDaemonConfig(t *testing.T) {
 			file: "sampledata/server.yaml",
 			DaemonExpected: &config.DaemonConfig{
 				IsDefault:       false,
+				File:            absPath("sampledata/server.yaml"),
 				ListenUrl:       "127.0.0.1:1234",
 				WatchDir:        "/var/mail",
 				Token:           "my_token",
 				DefChannel:      "general",
 				SendmailChannel: "general",
+				FallbackChannel: "alerts",
 				MailThrottling:  1000,
+				MboxUsers: map[string]string{
+					"alice": "@alice",
+					"bob":   "user:bob@example.com",
+				},
+				Severities: map[string]config.Severity{
+					"critical": {
+						Channel: "ops",
+						Mention: "here",
+					},
+				},
+				Hooks: map[string]config.Hook{
+					"gitlab": {
+						Text:    "{{ .user_name }} pushed to {{ .project.name }}",
+						Channel: "dev",
+					},
+				},
 			},
 			expectedErr: "",
 		},
@@ -110,8 +134,6 @@ func TestNewDaemonConfig(t *testing.T) {
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
 
-			viper.Reset()
-
 			cfg, err := config.NewDaemonConfig(tc.file)
 
 			if tc.expectedErr != "" && err != nil {
@@ -136,3 +158,47 @@ func TestNewDaemonConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestUnknownKeys(t *testing.T) {
+
+	t.Run("sample files", func(t *testing.T) {
+		for _, file := range []string{"sampledata/client.yaml", "sampledata/server.yaml", "../../resources/config/server.yaml", "../../resources/config/client.yaml"} {
+			got, err := config.UnknownKeys(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 {
+				t.Errorf("unexpected unknown keys in %s: %v", file, got)
+			}
+		}
+	})
+
+	t.Run("typos", func(t *testing.T) {
+		f, err := ioutil.TempFile("/tmp", "s2s_keys_*.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString(`---
+slack:
+  token: "my_token"
+  sendmail_channel: "general"
+daemon:
+  listen: ":4789"
+  smtp:
+    recipients:
+      ops@example.com: "#ops"
+  socket:
+`)
+		f.Close()
+
+		got, err := config.UnknownKeys(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"daemon.listen", "slack.sendmail_channel"}
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("unexpected unknown keys (-want +got):\n%s", diff)
+		}
+	})
+}
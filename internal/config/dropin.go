@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DropInDir is the directory next to server.yaml holding configuration files merged on top of it
+const DropInDir = "conf.d"
+
+// readDropIns merges the *.yaml files of the conf.d directory next to the configuration file in lexical order,
+// maps are merged and lists appended, setting a value that another file already set to something else is a conflict
+func readDropIns(v *viper.Viper, file string) ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(file), DropInDir, "*.yaml"))
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	cfg := settings(v)
+	origin := map[string]string{}
+	var conflicts []string
+	for _, f := range files {
+		dv := viper.New()
+		dv.SetConfigFile(f)
+		dv.SetConfigType("yaml")
+		err := dv.ReadInConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", f, err)
+		}
+		conflicts = append(conflicts, mergeSettings(cfg, settings(dv), "", f, file, origin)...)
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("conflicting configuration values: %s", strings.Join(conflicts, "; "))
+	}
+
+	err = v.MergeConfigMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// settings returns the values read from the configuration file, unlike AllSettings the keys of maps
+// containing dots, i.e. mail addresses, are kept
+func settings(v *viper.Viper) map[string]interface{} {
+	cfg := map[string]interface{}{}
+	for _, key := range v.AllKeys() {
+		section := strings.SplitN(key, ".", 2)[0]
+		if val := v.Get(section); val != nil {
+			cfg[section] = val
+		}
+	}
+	return cfg
+}
+
+// mergeSettings merges the values of src, read from file, into dst. origin keeps the file that set a key,
+// keys without origin are from the base configuration file
+func mergeSettings(dst, src map[string]interface{}, prefix, file, base string, origin map[string]string) []string {
+
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conflicts []string
+	for _, k := range keys {
+		key := prefix + k
+		sv := src[k]
+		dv := dst[k]
+		if sv == nil {
+			continue
+		}
+		if dv == nil {
+			dst[k] = sv
+			origin[key] = file
+			continue
+		}
+
+		switch d := dv.(type) {
+		case map[string]interface{}:
+			if s, ok := sv.(map[string]interface{}); ok {
+				conflicts = append(conflicts, mergeSettings(d, s, key+".", file, base, origin)...)
+				continue
+			}
+		case []interface{}:
+			if s, ok := sv.([]interface{}); ok {
+				dst[k] = append(d, s...)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(dv, sv) {
+			conflicts = append(conflicts, fmt.Sprintf("%s is %s in %s and %s in %s",
+				key, valueString(dv), originOf(origin, key, base), valueString(sv), file))
+		}
+	}
+	return conflicts
+}
+
+// originOf returns the file that set the key or one of its sections
+func originOf(origin map[string]string, key, base string) string {
+	for {
+		if f, ok := origin[key]; ok {
+			return f
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return base
+		}
+		key = key[:i]
+	}
+}
+
+func valueString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case map[string]interface{}:
+		return "a section"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%v", val)
+}
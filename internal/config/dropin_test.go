@@ -0,0 +1,172 @@
+package config_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"send2slack/internal/config"
+	"strings"
+	"testing"
+)
+
+const baseServerCfg = `---
+slack:
+  token: "my_token"
+  default_channel: "general"
+daemon:
+  listen_url: "127.0.0.1:4789"
+  hooks:
+    grafana:
+      channel: "ops"
+  logwatch:
+    - path: "/var/log/syslog"
+      patterns:
+        - match: "error"
+`
+
+func writeDropIns(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("/tmp", "s2s_dropin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(dir, config.DropInDir), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewDaemonConfig_DropIns(t *testing.T) {
+
+	t.Run("merge", func(t *testing.T) {
+		dir := writeDropIns(t, map[string]string{
+			"server.yaml": baseServerCfg,
+			"conf.d/10-ci.yaml": `---
+daemon:
+  hooks:
+    ci:
+      channel: "builds"
+  smtp:
+    recipients:
+      ops@example.com: "#ops"
+`,
+			"conf.d/20-nginx.yaml": `---
+slack:
+  default_channel: "general"
+daemon:
+  logwatch:
+    - path: "/var/log/nginx/error.log"
+`,
+			"conf.d/30-disabled.yaml.bak": `slack: {default_channel: "other"}`,
+		})
+		defer os.RemoveAll(dir)
+
+		cfg, err := config.NewDaemonConfig(dir + "/server.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedDropIns := []string{dir + "/conf.d/10-ci.yaml", dir + "/conf.d/20-nginx.yaml"}
+		if diff := cmp.Diff(expectedDropIns, cfg.DropIns); diff != "" {
+			t.Errorf("unexpected drop-in files (-want +got):\n%s", diff)
+		}
+		expectedHooks := map[string]config.Hook{
+			"grafana": {Channel: "ops"},
+			"ci":      {Channel: "builds"},
+		}
+		if diff := cmp.Diff(expectedHooks, cfg.Hooks); diff != "" {
+			t.Errorf("unexpected hooks (-want +got):\n%s", diff)
+		}
+		if len(cfg.LogWatch) != 2 || cfg.LogWatch[0].Path != "/var/log/syslog" || cfg.LogWatch[1].Path != "/var/log/nginx/error.log" {
+			t.Errorf("expected the log files to be appended in order, got %v", cfg.LogWatch)
+		}
+		if cfg.Smtp.Recipients["ops@example.com"] != "#ops" {
+			t.Errorf("unexpected smtp recipients %v", cfg.Smtp.Recipients)
+		}
+		if cfg.DefChannel != "general" || cfg.ListenUrl != "127.0.0.1:4789" {
+			t.Errorf("unexpected values of server.yaml, channel \"%s\", listen url \"%s\"", cfg.DefChannel, cfg.ListenUrl)
+		}
+	})
+
+	tcs := []struct {
+		name        string
+		files       map[string]string
+		expectedErr string
+	}{
+		{
+			name: "conflict with server.yaml",
+			files: map[string]string{
+				"conf.d/10-ci.yaml": "daemon:\n  hooks:\n    grafana:\n      channel: \"builds\"\n",
+			},
+			expectedErr: `conflicting configuration values: daemon.hooks.grafana.channel is "ops" in {dir}/server.yaml and "builds" in {dir}/conf.d/10-ci.yaml`,
+		},
+		{
+			name: "conflicts between drop-ins",
+			files: map[string]string{
+				"conf.d/10-ci.yaml":     "daemon:\n  hooks:\n    ci:\n      channel: \"builds\"\n  mbox_watch: \"/var/mail\"\n",
+				"conf.d/20-deploy.yaml": "daemon:\n  hooks:\n    ci:\n      channel: \"deploys\"\n  mbox_watch:\n    - \"/var/mail\"\n",
+			},
+			expectedErr: `conflicting configuration values: daemon.hooks.ci.channel is "builds" in {dir}/conf.d/10-ci.yaml and "deploys" in {dir}/conf.d/20-deploy.yaml; ` +
+				`daemon.mbox_watch is "/var/mail" in {dir}/conf.d/10-ci.yaml and a list in {dir}/conf.d/20-deploy.yaml`,
+		},
+		{
+			name: "invalid yaml",
+			files: map[string]string{
+				"conf.d/10-ci.yaml": "daemon: [",
+			},
+			expectedErr: "unable to read {dir}/conf.d/10-ci.yaml",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.files["server.yaml"] = baseServerCfg
+			dir := writeDropIns(t, tc.files)
+			defer os.RemoveAll(dir)
+
+			_, err := config.NewDaemonConfig(dir + "/server.yaml")
+			expected := strings.Replace(tc.expectedErr, "{dir}", dir, -1)
+			if err == nil || !strings.HasPrefix(err.Error(), expected) {
+				t.Errorf("unexpected error, got: \"%v\" expected: \"%s\"", err, expected)
+			}
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		dir := writeDropIns(t, map[string]string{
+			"server.yaml":       baseServerCfg,
+			"conf.d/10-ci.yaml": "daemon:\n  hook:\n    ci:\n      channel: \"builds\"\n",
+		})
+		defer os.RemoveAll(dir)
+
+		l := config.Loader{File: dir + "/server.yaml", Strict: true}
+		_, err := l.Daemon()
+		expected := "unknown configuration keys: daemon.hook.ci.channel (10-ci.yaml)"
+		if err == nil || err.Error() != expected {
+			t.Errorf("unexpected error, got: \"%v\" expected: \"%s\"", err, expected)
+		}
+	})
+
+	t.Run("not used by the client", func(t *testing.T) {
+		dir := writeDropIns(t, map[string]string{
+			"client.yaml":       "slack:\n  default_channel: \"general\"\n",
+			"conf.d/10-ci.yaml": "slack:\n  default_channel: \"builds\"\n",
+		})
+		defer os.RemoveAll(dir)
+
+		cfg, err := config.NewClientConfig(dir + "/client.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DefChannel != "general" {
+			t.Errorf("unexpected channel \"%s\"", cfg.DefChannel)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"sort"
+	"strings"
+)
+
+// configuration keys read by the client and the daemon, both can share a file, "*" matches any name, i.e. a user
+// or a hook, a trailing "*" also matches names containing dots like mail addresses
+var knownKeys = []string{
+	"slack.token",
+	"slack.token_file",
+	"slack.token_command",
+	"slack.default_channel",
+	"slack.email_channel",
+	"slack.fallback_channel",
+	"slack.upload_overflow",
+	"slack.severity.*.channel",
+	"slack.severity.*.color",
+	"slack.severity.*.emoji",
+	"slack.severity.*.mention",
+
+	"client.remote_url",
+	"client.tls.ca",
+	"client.tls.cert",
+	"client.tls.key",
+
+	"daemon.listen_url",
+	"daemon.mbox_watch",
+	"daemon.mbox_users.*",
+	"daemon.hooks.*.text",
+	"daemon.hooks.*.channel",
+	"daemon.hooks.*.color",
+	"daemon.github.secret",
+	"daemon.github.channel",
+	"daemon.github.repos.*",
+	"daemon.gitlab.secret",
+	"daemon.gitlab.channel",
+	"daemon.gitlab.repos.*",
+	"daemon.syslog.listen",
+	"daemon.syslog.rate_limit",
+	"daemon.syslog.filters",
+	"daemon.logwatch",
+	"daemon.smtp.listen",
+	"daemon.smtp.username",
+	"daemon.smtp.password",
+	"daemon.smtp.recipients.*",
+	"daemon.socket.owner",
+	"daemon.socket.group",
+	"daemon.socket.mode",
+	"daemon.tls.cert",
+	"daemon.tls.key",
+	"daemon.tls.client_ca",
+	"daemon.relay.url",
+	"daemon.relay.spool",
+	"daemon.relay.tls.ca",
+	"daemon.relay.tls.cert",
+	"daemon.relay.tls.key",
+}
+
+// UnknownKeys returns the keys of a configuration file that are not used by send2slack, i.e. typos
+func UnknownKeys(file string) ([]string, error) {
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if !isKnownKey(key) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func isKnownKey(key string) bool {
+	parts := strings.Split(key, ".")
+	for _, k := range knownKeys {
+		if matchKey(strings.Split(k, "."), parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchKey reports whether the key matches the pattern, sections without values, i.e. "daemon.smtp:" followed
+// only by comments, match as prefix of the pattern
+func matchKey(pattern []string, key []string) bool {
+	for i, p := range pattern {
+		if i >= len(key) {
+			return true
+		}
+		if p == "*" && i == len(pattern)-1 {
+			return true
+		}
+		if p != "*" && p != key[i] {
+			return false
+		}
+	}
+	return len(key) == len(pattern)
+}
+
+// HasPlainToken reports whether the configuration file contains the token itself instead of a token_file or
+// token_command
+func HasPlainToken(file string) (bool, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+	err := v.ReadInConfig()
+	if err != nil {
+		return false, err
+	}
+	return v.GetString("slack.token") != "", nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// prefix of the env variables, i.e. slack.default_channel is set with S2S_SLACK_DEFAULT_CHANNEL
+const envPrefix = "S2S_"
+
+// keys holding lists, they can only be set in the configuration file
+var listKeys = map[string]bool{
+	"daemon.syslog.filters": true,
+	"daemon.logwatch":       true,
+}
+
+// Loader reads the configuration of the client or the daemon, every load uses its own viper instance so that
+// loading one configuration does not affect the other. The values are taken in order of precedence from the
+// overrides (cli flags), the S2S_* env variables, the configuration file and the defaults
+type Loader struct {
+	File      string                 // configuration file, searched in ./, $HOME/.send2slack and /etc/send2slack if empty
+	Strict    bool                   // fail on keys and S2S_* env variables not used by send2slack
+	Overrides map[string]interface{} // values set with cli flags, i.e. "client.remote_url"
+}
+
+// source provides the values of a loaded configuration
+type source struct {
+	*viper.Viper
+	file      string   // configuration file used, empty if none was found
+	dropIns   []string // files of conf.d merged on top of the configuration file
+	overrides map[string]interface{}
+}
+
+// envName returns the env variable of a configuration key
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// envKeys returns the keys that can be set with env variables, maps and lists are only read from the file
+func envKeys() []string {
+	var keys []string
+	for _, k := range knownKeys {
+		if !strings.Contains(k, "*") && !listKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// load reads the configuration file, name is the file searched in the default paths, with dropIns the files in
+// conf.d next to it are merged on top
+func (l *Loader) load(name string, dropIns bool) (*source, error) {
+
+	v := viper.New()
+	if l.File != "" {
+		absPath, err := filepath.Abs(l.File)
+		if err != nil {
+			return nil, err
+		}
+		v.AddConfigPath(filepath.Dir(absPath))
+		v.SetConfigName(filepath.Base(absPath))
+	} else {
+		v.SetConfigName(name)
+		v.AddConfigPath(".")
+		v.AddConfigPath("$HOME/.send2slack")
+		v.AddConfigPath("/etc/send2slack/")
+	}
+	v.SetConfigType("yaml")
+
+	for _, key := range envKeys() {
+		err := v.BindEnv(key, envName(key))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	src := source{
+		Viper:     v,
+		overrides: l.Overrides,
+	}
+	err := v.ReadInConfig()
+	if _, ok := err.(viper.ConfigFileNotFoundError); err != nil && !ok {
+		return nil, err
+	} else if err == nil {
+		src.file = v.ConfigFileUsed()
+	}
+
+	if dropIns && src.file != "" {
+		src.dropIns, err = readDropIns(v, src.file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.Strict {
+		err = src.checkUnknown()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for key, val := range l.Overrides {
+		v.Set(key, val)
+	}
+	return &src, nil
+}
+
+// checkUnknown returns an error listing the keys of the file and the env variables not used by send2slack
+func (s *source) checkUnknown() error {
+
+	var unknown []string
+	files := s.dropIns
+	if s.file != "" {
+		files = append([]string{s.file}, files...)
+	}
+	for _, file := range files {
+		keys, err := UnknownKeys(file)
+		if err != nil {
+			return err
+		}
+		if file != s.file {
+			for i := range keys {
+				keys[i] = keys[i] + " (" + filepath.Base(file) + ")"
+			}
+		}
+		unknown = append(unknown, keys...)
+	}
+
+	known := map[string]bool{}
+	for _, key := range envKeys() {
+		known[envName(key)] = true
+	}
+	var unknownEnv []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, envPrefix) && !known[name] {
+			unknownEnv = append(unknownEnv, name)
+		}
+	}
+	sort.Strings(unknownEnv)
+	unknown = append(unknown, unknownEnv...)
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown configuration keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// UnmarshalKey decodes a section of the configuration, the single values of the section are overwritten by
+// the overrides and the env variables
+func (s *source) UnmarshalKey(key string, out interface{}) error {
+
+	tmp := viper.New()
+	if val := s.Viper.Get(key); val != nil {
+		tmp.Set(key, val)
+	}
+	for _, k := range envKeys() {
+		if !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		if val, ok := os.LookupEnv(envName(k)); ok {
+			tmp.Set(k, val)
+		}
+		if val, ok := s.overrides[k]; ok {
+			tmp.Set(k, val)
+		}
+	}
+	return tmp.UnmarshalKey(key, out)
+}
@@ -0,0 +1,145 @@
+package config_test
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"send2slack/internal/config"
+	"testing"
+)
+
+func TestLoader_Precedence(t *testing.T) {
+
+	os.Setenv("S2S_SLACK_DEFAULT_CHANNEL", "from-env")
+	defer os.Unsetenv("S2S_SLACK_DEFAULT_CHANNEL")
+	os.Setenv("S2S_DAEMON_SMTP_LISTEN", "127.0.0.1:2525")
+	defer os.Unsetenv("S2S_DAEMON_SMTP_LISTEN")
+	os.Setenv("S2S_CLIENT_TLS_CA", "/etc/ssl/env.pem")
+	defer os.Unsetenv("S2S_CLIENT_TLS_CA")
+
+	t.Run("env overwrites the file", func(t *testing.T) {
+		l := config.Loader{File: "sampledata/server.yaml"}
+		cfg, err := l.Daemon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DefChannel != "from-env" || cfg.SendmailChannel != "general" {
+			t.Errorf("unexpected channels, got default \"%s\" and email \"%s\"", cfg.DefChannel, cfg.SendmailChannel)
+		}
+		if cfg.Smtp.Listen != "127.0.0.1:2525" {
+			t.Errorf("unexpected smtp listen address \"%s\"", cfg.Smtp.Listen)
+		}
+		if cfg.MboxUsers["alice"] != "@alice" {
+			t.Errorf("expected the sections of the file to be kept, got mbox users %v", cfg.MboxUsers)
+		}
+	})
+
+	t.Run("env overwrites the defaults", func(t *testing.T) {
+		os.Setenv("S2S_DAEMON_LISTEN_URL", "unix:///run/send2slack.sock")
+		defer os.Unsetenv("S2S_DAEMON_LISTEN_URL")
+
+		l := config.Loader{File: "sampledata/doesNotExist"}
+		cfg, err := l.Daemon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ListenUrl != "unix:///run/send2slack.sock" || cfg.WatchDir != "false" {
+			t.Errorf("unexpected listen url \"%s\" or watch dir \"%s\"", cfg.ListenUrl, cfg.WatchDir)
+		}
+	})
+
+	t.Run("overrides win", func(t *testing.T) {
+		l := config.Loader{
+			File: "sampledata/client.yaml",
+			Overrides: map[string]interface{}{
+				"slack.default_channel": "from-flag",
+				"client.remote_url":     "false",
+				"client.tls.ca":         "/etc/ssl/flag.pem",
+			},
+		}
+		cfg, err := l.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DefChannel != "from-flag" || cfg.Mode != config.ModeDirectCli || cfg.TLS.CA != "/etc/ssl/flag.pem" {
+			t.Errorf("unexpected config, channel \"%s\", mode %d, ca \"%s\"", cfg.DefChannel, cfg.Mode, cfg.TLS.CA)
+		}
+	})
+
+	t.Run("loads are isolated", func(t *testing.T) {
+		_, err := (&config.Loader{File: "sampledata/server.yaml"}).Daemon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := (&config.Loader{File: "sampledata/client.yaml"}).Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.FallbackChannel != "" {
+			t.Errorf("expected the server configuration not to leak into the client, got fallback channel \"%s\"", cfg.FallbackChannel)
+		}
+	})
+}
+
+func TestLoader_Strict(t *testing.T) {
+
+	f, err := ioutil.TempFile("/tmp", "s2s_strict_*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("slack:\n  default_chanel: \"general\"\n")
+	f.Close()
+
+	tcs := []struct {
+		name        string
+		file        string
+		env         map[string]string
+		strict      bool
+		expectedErr string
+	}{
+		{
+			name: "unknown keys are ignored by default",
+			file: f.Name(),
+			env:  map[string]string{"S2S_SLACK_TOKN": "xoxb"},
+		},
+		{
+			name:        "unknown key in the file",
+			file:        f.Name(),
+			strict:      true,
+			expectedErr: "unknown configuration keys: slack.default_chanel",
+		},
+		{
+			name:        "unknown env variable",
+			file:        "sampledata/client.yaml",
+			env:         map[string]string{"S2S_SLACK_TOKN": "xoxb"},
+			strict:      true,
+			expectedErr: "unknown configuration keys: S2S_SLACK_TOKN",
+		},
+		{
+			name:   "known keys",
+			file:   "sampledata/client.yaml",
+			env:    map[string]string{"S2S_SLACK_FALLBACK_CHANNEL": "alerts"},
+			strict: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			l := config.Loader{File: tc.file, Strict: tc.strict}
+			_, err := l.Client()
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.expectedErr, got); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+)
+
+// shorter values are not redacted, they would hide unrelated text
+const minSecretLength = 6
+
+const redacted = "[REDACTED]"
+
+var (
+	secretsMutex sync.Mutex
+	secrets      []string
+)
+
+// RegisterSecret adds a value, i.e. the slack token, that is removed from logs and errors
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces the registered secrets in the text
+func Redact(text string) string {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, s := range secrets {
+		text = strings.Replace(text, s, redacted, -1)
+	}
+	return text
+}
+
+// RedactError returns the error with the secrets removed from its message
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return errors.New(msg)
+}
+
+// RedactHook removes the registered secrets from the messages and fields of log entries
+type RedactHook struct{}
+
+func (RedactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (RedactHook) Fire(entry *log.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = Redact(val)
+		case error:
+			entry.Data[k] = RedactError(val)
+		}
+	}
+	return nil
+}
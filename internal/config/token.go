@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// name of the systemd credential containing the token, i.e. LoadCredential=slack_token:/etc/send2slack/token
+const TokenCredential = "slack_token"
+
+// maximum time token_command may run
+const tokenCommandTimeout = 10 * time.Second
+
+// readToken returns the slack token, in order of precedence from $SLACK_TOKEN, the systemd credential,
+// slack.token_command, slack.token_file or slack.token, the token is registered to be redacted.
+// the S2S_SLACK_* env variables are checked before the file, i.e. S2S_SLACK_TOKEN replaces the
+// token_command of the file
+func readToken(src *source) (string, error) {
+	token, err := lookupToken(src)
+	if err != nil {
+		return "", err
+	}
+	RegisterSecret(token)
+	return token, nil
+}
+
+func lookupToken(src *source) (string, error) {
+
+	if token := os.Getenv("SLACK_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	// systemd passes credentials as files in $CREDENTIALS_DIRECTORY
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		token, err := readTokenFile(filepath.Join(dir, TokenCredential))
+		if err == nil || !os.IsNotExist(err) {
+			return token, err
+		}
+	}
+
+	for _, get := range []func(string) string{envValue, src.GetString} {
+		if cmd := get("slack.token_command"); cmd != "" {
+			return runTokenCommand(cmd)
+		}
+		if file := get("slack.token_file"); file != "" {
+			token, err := readTokenFile(file)
+			if err != nil {
+				return "", fmt.Errorf("unable to read token_file: %v", err)
+			}
+			return token, nil
+		}
+		if token := get("slack.token"); token != "" {
+			return token, nil
+		}
+	}
+	return "", nil
+}
+
+// envValue returns the value of the S2S_* env variable of a configuration key
+func envValue(key string) string {
+	return os.Getenv(envName(key))
+}
+
+// readTokenFile returns the content of the file without surrounding whitespace
+func readTokenFile(file string) (string, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// runTokenCommand runs the command with the shell and returns its output, i.e. "pass show slack", only stderr
+// is added to errors since the output could contain the token
+func runTokenCommand(command string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("token_command failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// the first line is used, password managers like pass add metadata on the following lines
+	token := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	if token == "" {
+		return "", fmt.Errorf("token_command returned no token")
+	}
+	return token, nil
+}
@@ -0,0 +1,250 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"send2slack/internal/sender"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// alertmanagerPayload is the webhook payload sent by prometheus alertmanager, grafana unified alerting
+// uses the same format extended by a title and a pre rendered message
+type alertmanagerPayload struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []alert           `json:"alerts"`
+
+	// only set by grafana
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+type alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// grafanaLegacyPayload is the webhook payload of the grafana legacy alerting
+type grafanaLegacyPayload struct {
+	Title       string `json:"title"`
+	RuleId      int    `json:"ruleId"`
+	RuleName    string `json:"ruleName"`
+	RuleUrl     string `json:"ruleUrl"`
+	State       string `json:"state"`
+	Message     string `json:"message"`
+	EvalMatches []struct {
+		Metric string  `json:"metric"`
+		Value  float64 `json:"value"`
+	} `json:"evalMatches"`
+}
+
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alertmanagerHandlerFunc receives alertmanager webhooks, every alert group is sent as one message
+// and later notifications of the same group are replied in the thread of the first one
+func (srv *Server) alertmanagerHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	var payload alertmanagerPayload
+	if !decodeWebhook(w, r, &payload) {
+		return
+	}
+
+	msg := newAlertMessage(&payload, "alertmanager")
+	msg.Destination = r.URL.Query().Get("channel")
+	srv.deliverMessage(w, msg)
+}
+
+// grafanaHandlerFunc receives grafana webhooks, both from the legacy and the unified alerting
+func (srv *Server) grafanaHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	var raw json.RawMessage
+	if !decodeWebhook(w, r, &raw) {
+		return
+	}
+
+	var msg *sender.Message
+
+	// unified alerting sends an alertmanager like payload containing a list of alerts
+	var payload alertmanagerPayload
+	err := json.Unmarshal(raw, &payload)
+	if err == nil && payload.Alerts != nil {
+		msg = newAlertMessage(&payload, "grafana")
+	} else {
+		var legacy grafanaLegacyPayload
+		err = json.Unmarshal(raw, &legacy)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "400: error decoding json body")
+			return
+		}
+		msg = newGrafanaLegacyMessage(&legacy)
+	}
+
+	msg.Destination = r.URL.Query().Get("channel")
+	srv.deliverMessage(w, msg)
+}
+
+// decodeWebhook only accepts POST requests and decodes the json body into v,
+// returns false if the request was rejected and the response already written
+func decodeWebhook(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "404")
+		return false
+	}
+
+	// numbers are kept as json.Number, large numbers would be rendered in exponent notation as float64
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	dec.UseNumber()
+	err := dec.Decode(v)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error decoding json body")
+		return false
+	}
+	return true
+}
+
+// newAlertMessage composes the slack message for an alert group
+func newAlertMessage(p *alertmanagerPayload, source string) *sender.Message {
+
+	status := strings.ToLower(p.Status)
+	firing := 0
+	for _, a := range p.Alerts {
+		if a.Status == alertFiring {
+			firing++
+		}
+	}
+
+	var sb strings.Builder
+	if p.Title != "" {
+		sb.WriteString("*" + p.Title + "*\n")
+	} else {
+		name := p.GroupLabels["alertname"]
+		if name == "" {
+			name = p.CommonLabels["alertname"]
+		}
+		count := len(p.Alerts)
+		if status == alertFiring {
+			count = firing
+		}
+		sb.WriteString(fmt.Sprintf("*[%s:%d] %s*", strings.ToUpper(status), count, name))
+		if labels := formatLabels(p.GroupLabels, "alertname"); labels != "" {
+			sb.WriteString(" (" + labels + ")")
+		}
+		sb.WriteString("\n")
+	}
+
+	if p.Message != "" {
+		sb.WriteString(p.Message)
+	} else {
+		for _, a := range p.Alerts {
+			sb.WriteString("• [" + a.Status + "] " + alertSummary(a))
+			if labels := formatLabels(a.Labels, keys(p.CommonLabels)...); labels != "" {
+				sb.WriteString(" `" + labels + "`")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	msg := sender.Message{
+		Text:      strings.TrimSpace(sb.String()),
+		Color:     "red",
+		ThreadKey: source + ":" + p.GroupKey,
+	}
+	if status == alertResolved {
+		msg.Color = "green"
+		msg.Resolved = true
+	}
+
+	// use the severity label of the alerts if it is a known severity
+	sev := strings.ToLower(p.CommonLabels["severity"])
+	if sender.IsValidSeverity(sev) {
+		msg.Severity = sev
+	}
+	return &msg
+}
+
+// newGrafanaLegacyMessage composes the slack message for an alert of the grafana legacy alerting
+func newGrafanaLegacyMessage(p *grafanaLegacyPayload) *sender.Message {
+
+	var sb strings.Builder
+	sb.WriteString("*" + p.Title + "*\n")
+	if p.Message != "" {
+		sb.WriteString(p.Message + "\n")
+	}
+	for _, m := range p.EvalMatches {
+		sb.WriteString("• " + m.Metric + ": " + strconv.FormatFloat(m.Value, 'f', -1, 64) + "\n")
+	}
+	if p.RuleUrl != "" {
+		sb.WriteString("<" + p.RuleUrl + "|" + p.RuleName + ">")
+	}
+
+	msg := sender.Message{
+		Text:      strings.TrimSpace(sb.String()),
+		ThreadKey: "grafana:" + strconv.Itoa(p.RuleId) + ":" + p.RuleName,
+	}
+	switch p.State {
+	case "alerting":
+		msg.Color = "red"
+	case "ok":
+		msg.Color = "green"
+	case "no_data":
+		msg.Color = "orange"
+	default:
+		msg.Color = "blue"
+	}
+	return &msg
+}
+
+// alertSummary returns the most descriptive text of an alert
+func alertSummary(a alert) string {
+	for _, k := range []string{"summary", "description", "message"} {
+		if v := a.Annotations[k]; v != "" {
+			return v
+		}
+	}
+	return a.Labels["alertname"]
+}
+
+// formatLabels returns the labels as sorted k=v list, skipping the excluded keys
+func formatLabels(labels map[string]string, exclude ...string) string {
+	excluded := map[string]bool{}
+	for _, e := range exclude {
+		excluded[e] = true
+	}
+
+	var l []string
+	for _, k := range keys(labels) {
+		if !excluded[k] {
+			l = append(l, k+"="+labels[k])
+		}
+	}
+	return strings.Join(l, ", ")
+}
+
+// keys returns the sorted keys of a map
+func keys(m map[string]string) []string {
+	k := make([]string, 0, len(m))
+	for key := range m {
+		k = append(k, key)
+	}
+	sort.Strings(k)
+	return k
+}
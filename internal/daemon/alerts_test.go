@@ -0,0 +1,189 @@
+package daemon_test
+
+import (
+	"bytes"
+	"github.com/phayes/freeport"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const alertmanagerFiring = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"HighLatency\"}",
+  "status": "firing",
+  "groupLabels": {"alertname": "HighLatency"},
+  "commonLabels": {"alertname": "HighLatency", "severity": "critical"},
+  "alerts": [
+    {"status": "firing", "labels": {"alertname": "HighLatency", "severity": "critical", "instance": "web-01"}, "annotations": {"summary": "latency above 1s"}},
+    {"status": "firing", "labels": {"alertname": "HighLatency", "severity": "critical", "instance": "web-02"}, "annotations": {"summary": "latency above 1s"}}
+  ]
+}`
+
+const alertmanagerResolved = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"HighLatency\"}",
+  "status": "resolved",
+  "groupLabels": {"alertname": "HighLatency"},
+  "commonLabels": {"alertname": "HighLatency", "instance": "web-01"},
+  "alerts": [
+    {"status": "resolved", "labels": {"alertname": "HighLatency", "instance": "web-01"}, "annotations": {"summary": "latency above 1s"}}
+  ]
+}`
+
+const grafanaLegacy = `{
+  "title": "[Alerting] Disk usage",
+  "ruleId": 3,
+  "ruleName": "Disk usage",
+  "ruleUrl": "http://grafana/d/1",
+  "state": "alerting",
+  "message": "disk almost full",
+  "evalMatches": [{"metric": "sda1", "value": 97.5}]
+}`
+
+const grafanaUnified = `{
+  "status": "resolved",
+  "groupKey": "{}:{alertname=\"Disk\"}",
+  "title": "[RESOLVED] Disk",
+  "message": "all disks ok",
+  "alerts": [{"status": "resolved", "labels": {"alertname": "Disk"}}]
+}`
+
+type alertTc struct {
+	name         string
+	path         string
+	body         string
+	expectedCode int
+	expected     sender.Message
+}
+
+// startDummyServer starts a server on a free port that uses a dummy message sender
+func startDummyServer(t *testing.T, cfg *config.DaemonConfig) (int, *sender.DummyMessageSender, func()) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ListenUrl = ":" + strconv.Itoa(port)
+
+	srv, err := daemon.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummySender := sender.DummyMessageSender{}
+	srv.MsgSender = &dummySender
+
+	srv.StartBackground()
+	time.Sleep(100 * time.Millisecond)
+	return port, &dummySender, srv.Stop
+}
+
+func TestServerAlertWebhooks(t *testing.T) {
+
+	port, dummySender, stop := startDummyServer(t, &config.DaemonConfig{})
+	defer stop()
+
+	tcs := []alertTc{
+		{
+			name:         "alertmanager firing",
+			path:         "/alertmanager?channel=ops",
+			body:         alertmanagerFiring,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Destination: "ops",
+				Text:        "*[FIRING:2] HighLatency*\n• [firing] latency above 1s `instance=web-01`\n• [firing] latency above 1s `instance=web-02`",
+				Color:       "red",
+				Severity:    "critical",
+				ThreadKey:   "alertmanager:{}:{alertname=\"HighLatency\"}",
+			},
+		},
+		{
+			name:         "alertmanager resolved",
+			path:         "/alertmanager",
+			body:         alertmanagerResolved,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:      "*[RESOLVED:1] HighLatency*\n• [resolved] latency above 1s",
+				Color:     "green",
+				Resolved:  true,
+				ThreadKey: "alertmanager:{}:{alertname=\"HighLatency\"}",
+			},
+		},
+		{
+			name:         "grafana legacy alert",
+			path:         "/grafana",
+			body:         grafanaLegacy,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:      "*[Alerting] Disk usage*\ndisk almost full\n• sda1: 97.5\n<http://grafana/d/1|Disk usage>",
+				Color:     "red",
+				ThreadKey: "grafana:3:Disk usage",
+			},
+		},
+		{
+			name:         "grafana unified alert",
+			path:         "/grafana",
+			body:         grafanaUnified,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:      "*[RESOLVED] Disk*\nall disks ok",
+				Color:     "green",
+				Resolved:  true,
+				ThreadKey: "grafana:{}:{alertname=\"Disk\"}",
+			},
+		},
+		{
+			name:         "invalid payload",
+			path:         "/alertmanager",
+			body:         "not json",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dummySender.Last = sender.Message{}
+
+			resp, err := http.Post("http://localhost:"+strconv.Itoa(port)+tc.path, "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("wrong status code: got %d, expected %d", resp.StatusCode, tc.expectedCode)
+			}
+			if tc.expectedCode != http.StatusAccepted {
+				return
+			}
+
+			got := dummySender.Last
+			if got.Text != tc.expected.Text {
+				t.Errorf("unexpected text, got:\n%s\nexpected:\n%s", got.Text, tc.expected.Text)
+			}
+			if got.Destination != tc.expected.Destination || got.Color != tc.expected.Color ||
+				got.Severity != tc.expected.Severity || got.ThreadKey != tc.expected.ThreadKey || got.Resolved != tc.expected.Resolved {
+				t.Errorf("unexpected message, got: %+v expected: %+v", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("empty body", func(t *testing.T) {
+		resp, err := http.Post("http://localhost:"+strconv.Itoa(port)+"/grafana", "application/json", bytes.NewBuffer(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("wrong status code: got %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"send2slack/internal/config"
+	"send2slack/internal/sender"
 	"sync/atomic"
 )
 
@@ -15,8 +16,9 @@ type daemon struct {
 
 func NewDaemon(cfg *config.DaemonConfig) (*daemon, error) {
 
-	if cfg.ListenUrl == "false" && cfg.WatchDir == "false" {
-		return nil, errors.New("both mbox-watch and server have been disabled")
+	if cfg.ListenUrl == "false" && cfg.WatchDir == "false" && cfg.Syslog.Listen == "" && len(cfg.LogWatch) == 0 &&
+		cfg.Smtp.Listen == "" {
+		return nil, errors.New("mbox-watch, server, syslog, logwatch and smtp have been disabled")
 	}
 
 	d := daemon{
@@ -40,6 +42,8 @@ func (d *daemon) Start() {
 
 	if atomic.CompareAndSwapInt32(&d.running, 0, 1) {
 
+		d.validateChannels()
+
 		if d.cfg.ListenUrl != "false" {
 			server, err := NewServer(d.cfg)
 			if err != nil {
@@ -57,10 +61,71 @@ func (d *daemon) Start() {
 			watcher.StartBackground()
 		}
 
+		if d.cfg.Syslog.Listen != "" {
+			receiver, err := NewSyslogReceiver(d.cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			receiver.StartBackground()
+		}
+
+		if len(d.cfg.LogWatch) > 0 {
+			logWatcher, err := NewLogWatcher(d.cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			logWatcher.StartBackground()
+		}
+
+		if d.cfg.Smtp.Listen != "" {
+			smtpServer, err := NewSmtpServer(d.cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			smtpServer.StartBackground()
+		}
+
+		if d.cfg.Relay.Url != "" && d.cfg.Relay.Spool != "" {
+			go flushRelaySpool(d.cfg, d.done)
+		}
+
 		<-d.done
 	}
 }
 
+// validateChannels verifies that the configured channels exist in slack, unknown channels are only
+// reported since messages can still be delivered to the fallback channel
+func (d *daemon) validateChannels() {
+
+	// in relay mode the channels are resolved by the upstream server
+	if d.cfg.Token == "" || d.cfg.Relay.Url != "" {
+		return
+	}
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Token: d.cfg.Token,
+		Mode:  config.ModeDirectCli,
+	})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	channels := [][]string{
+		{"default_channel", d.cfg.DefChannel},
+		{"email_channel", d.cfg.SendmailChannel},
+		{"fallback_channel", d.cfg.FallbackChannel},
+	}
+	for _, ch := range channels {
+		if ch[1] == "" {
+			continue
+		}
+		if err := sndr.ValidateDestination(ch[1]); err != nil {
+			log.Errorf("configured %s \"%s\" is not valid: %v", ch[0], ch[1], err)
+		}
+	}
+}
+
 // Start the Server in a non blocking way in a separate routine
 func (d *daemon) StartBackground() {
 	if atomic.LoadInt32(&d.running) == 0 {
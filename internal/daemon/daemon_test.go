@@ -74,7 +74,7 @@ func prePateStage() (path string, port int, er error) {
 slack:
   token: "my_token2"
   default_channel: "general"
-  sendmail_channel: "general"
+  email_channel: "general"
 daemon:
   listen_url: "` + listenUrl + `"
   mbox_watch: "` + tmpDir + `/mbox"
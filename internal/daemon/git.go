@@ -0,0 +1,321 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+)
+
+// maximum size of a webhook payload
+const maxWebhookSize = 10 << 20
+
+// maximum amount of commits listed in a push message
+const maxListedCommits = 5
+
+type gitCommit struct {
+	Id      string `json:"id"`
+	Message string `json:"message"`
+	Url     string `json:"url"`
+}
+
+// githubPayload contains the fields used of the github push, pull_request, release and workflow_run events
+type githubPayload struct {
+	Action     string      `json:"action"`
+	Ref        string      `json:"ref"`
+	Compare    string      `json:"compare"`
+	Commits    []gitCommit `json:"commits"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	PullRequest struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HtmlUrl string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+	} `json:"pull_request"`
+	Release struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		HtmlUrl string `json:"html_url"`
+	} `json:"release"`
+	WorkflowRun struct {
+		Name       string `json:"name"`
+		HeadBranch string `json:"head_branch"`
+		Conclusion string `json:"conclusion"`
+		HtmlUrl    string `json:"html_url"`
+	} `json:"workflow_run"`
+}
+
+// gitlabPayload contains the fields used of the gitlab push, pipeline, merge request and release events
+type gitlabPayload struct {
+	ObjectKind        string      `json:"object_kind"`
+	Ref               string      `json:"ref"`
+	UserName          string      `json:"user_name"`
+	TotalCommitsCount int         `json:"total_commits_count"`
+	Commits           []gitCommit `json:"commits"`
+	Project           struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebUrl            string `json:"web_url"`
+	} `json:"project"`
+	User struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectAttributes struct {
+		Id     int    `json:"id"`
+		Iid    int    `json:"iid"`
+		Title  string `json:"title"`
+		Url    string `json:"url"`
+		Action string `json:"action"`
+		Status string `json:"status"`
+		Ref    string `json:"ref"`
+	} `json:"object_attributes"`
+	// release events have the attributes on the top level
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Url    string `json:"url"`
+}
+
+// githubHandlerFunc receives github webhooks, the payload signature is verified with the configured secret
+func (srv *Server) githubHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	body, ok := readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(srv.github.Secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Hub-Signature-256"))) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "403: invalid signature")
+		return
+	}
+
+	var p githubPayload
+	err := json.Unmarshal(body, &p)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error decoding json body")
+		return
+	}
+
+	msg := newGithubMessage(r.Header.Get("X-GitHub-Event"), &p)
+	if msg == nil {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "ignored")
+		return
+	}
+	msg.Destination = repoChannel(srv.github, p.Repository.FullName)
+	srv.deliverMessage(w, msg)
+}
+
+// gitlabHandlerFunc receives gitlab webhooks, the request is verified with the configured secret token
+func (srv *Server) gitlabHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	body, ok := readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.Header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(srv.gitlab.Secret)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "403: invalid token")
+		return
+	}
+
+	var p gitlabPayload
+	err := json.Unmarshal(body, &p)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error decoding json body")
+		return
+	}
+
+	msg := newGitlabMessage(&p)
+	if msg == nil {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "ignored")
+		return
+	}
+	msg.Destination = repoChannel(srv.gitlab, p.Project.PathWithNamespace)
+	srv.deliverMessage(w, msg)
+}
+
+// readWebhook only accepts POST requests and reads the request body,
+// returns false if the request was rejected and the response already written
+func readWebhook(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "404")
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error reading body")
+		return nil, false
+	}
+	return body, true
+}
+
+// repoChannel returns the channel configured for a repository or the default channel of the receiver
+func repoChannel(cfg config.GitWebhook, repo string) string {
+	if c, ok := cfg.Repos[strings.ToLower(repo)]; ok {
+		return c
+	}
+	return cfg.Channel
+}
+
+// newGithubMessage composes the message for a github event, returns nil for events that are not notified
+func newGithubMessage(event string, p *githubPayload) *sender.Message {
+
+	prefix := "*[" + p.Repository.FullName + "]* "
+	switch event {
+	case "push":
+		if len(p.Commits) == 0 {
+			return nil
+		}
+		text := prefix + p.Sender.Login + " pushed " + commitCount(len(p.Commits)) + " to " + refName(p.Ref)
+		if p.Compare != "" {
+			text += " (<" + p.Compare + "|compare>)"
+		}
+		return &sender.Message{Text: text + listCommits(p.Commits), Color: "blue"}
+
+	case "pull_request":
+		pr := fmt.Sprintf("<%s|#%d %s>", p.PullRequest.HtmlUrl, p.PullRequest.Number, p.PullRequest.Title)
+		switch p.Action {
+		case "opened", "reopened":
+			return &sender.Message{Text: prefix + p.Sender.Login + " " + p.Action + " pull request " + pr, Color: "blue"}
+		case "closed":
+			if p.PullRequest.Merged {
+				return &sender.Message{Text: prefix + p.Sender.Login + " merged pull request " + pr, Color: "green"}
+			}
+			return &sender.Message{Text: prefix + p.Sender.Login + " closed pull request " + pr, Color: "orange"}
+		}
+
+	case "release":
+		if p.Action == "published" {
+			name := p.Release.Name
+			if name == "" {
+				name = p.Release.TagName
+			}
+			text := prefix + "release <" + p.Release.HtmlUrl + "|" + name + "> published by " + p.Sender.Login
+			return &sender.Message{Text: text, Color: "green"}
+		}
+
+	case "workflow_run":
+		if p.Action == "completed" {
+			run := p.WorkflowRun
+			text := prefix + "workflow <" + run.HtmlUrl + "|" + run.Name + "> on " + run.HeadBranch + ": " + run.Conclusion
+			return &sender.Message{Text: text, Color: statusColor(run.Conclusion)}
+		}
+	}
+	return nil
+}
+
+// newGitlabMessage composes the message for a gitlab event, returns nil for events that are not notified
+func newGitlabMessage(p *gitlabPayload) *sender.Message {
+
+	prefix := "*[" + p.Project.PathWithNamespace + "]* "
+	attr := p.ObjectAttributes
+	switch p.ObjectKind {
+	case "push":
+		if p.TotalCommitsCount == 0 {
+			return nil
+		}
+		text := prefix + p.UserName + " pushed " + commitCount(p.TotalCommitsCount) + " to " + refName(p.Ref)
+		return &sender.Message{Text: text + listCommits(p.Commits), Color: "blue"}
+
+	case "pipeline":
+		switch attr.Status {
+		case "success", "failed", "canceled":
+			url := p.Project.WebUrl + "/-/pipelines/" + strconv.Itoa(attr.Id)
+			text := prefix + "pipeline <" + url + "|#" + strconv.Itoa(attr.Id) + "> on " + attr.Ref + ": " + attr.Status
+			return &sender.Message{Text: text, Color: statusColor(attr.Status)}
+		}
+
+	case "merge_request":
+		mr := fmt.Sprintf("<%s|!%d %s>", attr.Url, attr.Iid, attr.Title)
+		switch attr.Action {
+		case "open", "reopen":
+			return &sender.Message{Text: prefix + p.User.Username + " " + attr.Action + "ed merge request " + mr, Color: "blue"}
+		case "merge":
+			return &sender.Message{Text: prefix + p.User.Username + " merged merge request " + mr, Color: "green"}
+		case "close":
+			return &sender.Message{Text: prefix + p.User.Username + " closed merge request " + mr, Color: "orange"}
+		}
+
+	case "release":
+		if p.Action == "create" {
+			name := p.Name
+			if name == "" {
+				name = p.Tag
+			}
+			return &sender.Message{Text: prefix + "release <" + p.Url + "|" + name + "> published", Color: "green"}
+		}
+	}
+	return nil
+}
+
+// listCommits returns the first commits as list, one line per commit
+func listCommits(commits []gitCommit) string {
+	var sb strings.Builder
+	for i, c := range commits {
+		if i == maxListedCommits {
+			sb.WriteString("\n• … " + strconv.Itoa(len(commits)-maxListedCommits) + " more")
+			break
+		}
+		id := c.Id
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		title := strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+		sb.WriteString("\n• <" + c.Url + "|" + id + "> " + title)
+	}
+	return sb.String()
+}
+
+func commitCount(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return strconv.Itoa(n) + " commits"
+}
+
+// refName returns the branch or tag name of a git ref
+func refName(ref string) string {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return "tag " + strings.TrimPrefix(ref, "refs/tags/")
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
+
+// statusColor returns the message color for the result of a pipeline
+func statusColor(status string) string {
+	switch status {
+	case "success":
+		return "green"
+	case "failed", "failure", "timed_out":
+		return "red"
+	default:
+		return "orange"
+	}
+}
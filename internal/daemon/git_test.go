@@ -0,0 +1,188 @@
+package daemon_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const githubPush = `{
+  "ref": "refs/heads/main",
+  "compare": "https://github.com/org/repo/compare/a...b",
+  "repository": {"full_name": "Org/Repo"},
+  "sender": {"login": "alice"},
+  "commits": [
+    {"id": "0123456789abcdef", "message": "fix the bug\n\nlong description", "url": "https://github.com/org/repo/commit/0123456789abcdef"}
+  ]
+}`
+
+const githubPrMerged = `{
+  "action": "closed",
+  "repository": {"full_name": "org/other"},
+  "sender": {"login": "bob"},
+  "pull_request": {"number": 12, "title": "add feature", "html_url": "https://github.com/org/other/pull/12", "merged": true}
+}`
+
+const gitlabPipeline = `{
+  "object_kind": "pipeline",
+  "project": {"path_with_namespace": "group/project", "web_url": "https://gitlab.com/group/project"},
+  "object_attributes": {"id": 99, "ref": "main", "status": "failed"}
+}`
+
+const gitlabMr = `{
+  "object_kind": "merge_request",
+  "project": {"path_with_namespace": "group/project"},
+  "user": {"username": "carol"},
+  "object_attributes": {"iid": 7, "title": "refactor", "url": "https://gitlab.com/group/project/-/merge_requests/7", "action": "open"}
+}`
+
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestServerGitWebhooks(t *testing.T) {
+
+	port, dummySender, stop := startDummyServer(t, &config.DaemonConfig{
+		Github: config.GitWebhook{
+			Secret:  "gh-secret",
+			Channel: "dev",
+			Repos: map[string]string{
+				"org/repo": "backend",
+			},
+		},
+		Gitlab: config.GitWebhook{
+			Secret: "gl-secret",
+		},
+	})
+	defer stop()
+
+	tcs := []struct {
+		name         string
+		path         string
+		body         string
+		headers      map[string]string
+		expectedCode int
+		expected     sender.Message
+	}{
+		{
+			name: "github push",
+			path: "/github",
+			body: githubPush,
+			headers: map[string]string{
+				"X-GitHub-Event":      "push",
+				"X-Hub-Signature-256": githubSignature("gh-secret", githubPush),
+			},
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text: "*[Org/Repo]* alice pushed 1 commit to main (<https://github.com/org/repo/compare/a...b|compare>)\n" +
+					"• <https://github.com/org/repo/commit/0123456789abcdef|01234567> fix the bug",
+				Destination: "backend",
+				Color:       "blue",
+			},
+		},
+		{
+			name: "github merged pull request",
+			path: "/github",
+			body: githubPrMerged,
+			headers: map[string]string{
+				"X-GitHub-Event":      "pull_request",
+				"X-Hub-Signature-256": githubSignature("gh-secret", githubPrMerged),
+			},
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:        "*[org/other]* bob merged pull request <https://github.com/org/other/pull/12|#12 add feature>",
+				Destination: "dev",
+				Color:       "green",
+			},
+		},
+		{
+			name: "github invalid signature",
+			path: "/github",
+			body: githubPush,
+			headers: map[string]string{
+				"X-GitHub-Event":      "push",
+				"X-Hub-Signature-256": githubSignature("wrong", githubPush),
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "github ignored event",
+			path: "/github",
+			body: `{}`,
+			headers: map[string]string{
+				"X-GitHub-Event":      "ping",
+				"X-Hub-Signature-256": githubSignature("gh-secret", `{}`),
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name: "gitlab failed pipeline",
+			path: "/gitlab",
+			body: gitlabPipeline,
+			headers: map[string]string{
+				"X-Gitlab-Token": "gl-secret",
+			},
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:  "*[group/project]* pipeline <https://gitlab.com/group/project/-/pipelines/99|#99> on main: failed",
+				Color: "red",
+			},
+		},
+		{
+			name: "gitlab merge request",
+			path: "/gitlab",
+			body: gitlabMr,
+			headers: map[string]string{
+				"X-Gitlab-Token": "gl-secret",
+			},
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:  "*[group/project]* carol opened merge request <https://gitlab.com/group/project/-/merge_requests/7|!7 refactor>",
+				Color: "blue",
+			},
+		},
+		{
+			name:         "gitlab missing token",
+			path:         "/gitlab",
+			body:         gitlabMr,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dummySender.Last = sender.Message{}
+
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:"+strconv.Itoa(port)+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("wrong status code: got %d, expected %d", resp.StatusCode, tc.expectedCode)
+			}
+
+			got := dummySender.Last
+			if got.Text != tc.expected.Text || got.Destination != tc.expected.Destination || got.Color != tc.expected.Color {
+				t.Errorf("unexpected message, got: %+v expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const hooksPath = "/hooks/"
+
+// hook holds the parsed templates of a generic webhook
+type hook struct {
+	text       *template.Template
+	channel    *template.Template
+	color      *template.Template
+	hasChannel bool // the channel is set by the template, it must not render empty
+}
+
+// functions available in the hook templates
+var hookFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"default": func(def interface{}, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	// added to every printed pipeline by renderMissingEmpty
+	"missingEmpty": func(v interface{}) interface{} {
+		if v == nil {
+			return ""
+		}
+		return v
+	},
+}
+
+// parseHookTemplate parses a template of a hook, values missing from the payload or null render empty,
+// text/template would print them as "<no value>" even with missingkey=zero
+func parseHookTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(hookFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			renderMissingEmpty(t.Tree, t.Tree.Root)
+		}
+	}
+	return tmpl, nil
+}
+
+// renderMissingEmpty pipes the value of every action that prints a value through missingEmpty
+func renderMissingEmpty(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			renderMissingEmpty(tree, child)
+		}
+	case *parse.ActionNode:
+		// assignments do not print anything
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		ident := parse.NewIdentifier("missingEmpty").SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+	case *parse.IfNode:
+		renderMissingEmpty(tree, n.List)
+		renderMissingEmpty(tree, n.ElseList)
+	case *parse.RangeNode:
+		renderMissingEmpty(tree, n.List)
+		renderMissingEmpty(tree, n.ElseList)
+	case *parse.WithNode:
+		renderMissingEmpty(tree, n.List)
+		renderMissingEmpty(tree, n.ElseList)
+	}
+}
+
+// newHooks parses the templates of all the configured hooks
+func newHooks(cfg map[string]config.Hook) (map[string]*hook, error) {
+
+	hooks := map[string]*hook{}
+	for name, c := range cfg {
+		if c.Text == "" {
+			return nil, fmt.Errorf("hook \"%s\": text template cannot be empty", name)
+		}
+
+		h := hook{hasChannel: strings.TrimSpace(c.Channel) != ""}
+		var err error
+		h.text, err = parseHookTemplate(name+".text", c.Text)
+		if err != nil {
+			return nil, fmt.Errorf("hook \"%s\": %v", name, err)
+		}
+		h.channel, err = parseHookTemplate(name+".channel", c.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("hook \"%s\": %v", name, err)
+		}
+		h.color, err = parseHookTemplate(name+".color", c.Color)
+		if err != nil {
+			return nil, fmt.Errorf("hook \"%s\": %v", name, err)
+		}
+		hooks[strings.ToLower(name)] = &h
+	}
+	return hooks, nil
+}
+
+// newMessage renders the templates of the hook using the payload, fields missing from the payload render empty
+func (h *hook) newMessage(payload interface{}) (*sender.Message, error) {
+
+	exec := func(tmpl *template.Template) (string, error) {
+		var out bytes.Buffer
+		err := tmpl.Execute(&out, payload)
+		return strings.TrimSpace(out.String()), err
+	}
+
+	text, err := exec(h.text)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := exec(h.channel)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" && h.hasChannel {
+		return nil, fmt.Errorf("the channel template rendered an empty channel")
+	}
+	color, err := exec(h.color)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := sender.Message{
+		Text:        text,
+		Destination: channel,
+		Color:       color,
+	}
+	return &msg, nil
+}
+
+// hooksHandlerFunc receives arbitrary json payloads on /hooks/<name> and sends them
+// using the templates configured for the hook
+func (srv *Server) hooksHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	name := strings.ToLower(strings.TrimPrefix(r.URL.Path, hooksPath))
+	h, ok := srv.hooks[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "404")
+		return
+	}
+
+	var payload interface{}
+	if !decodeWebhook(w, r, &payload) {
+		return
+	}
+
+	msg, err := h.newMessage(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error executing hook template: %v", err)
+		return
+	}
+
+	srv.deliverMessage(w, msg)
+}
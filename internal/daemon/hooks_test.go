@@ -0,0 +1,141 @@
+package daemon_test
+
+import (
+	"net/http"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestServerHooks(t *testing.T) {
+
+	port, dummySender, stop := startDummyServer(t, &config.DaemonConfig{
+		Hooks: map[string]config.Hook{
+			"jenkins": {
+				Text:    "build {{ .build.number }} of {{ .name }}: {{ .build.status | lower }}",
+				Channel: "{{ .channel | default \"ci\" }}",
+				Color:   "{{ if eq .build.status \"FAILURE\" }}red{{ else }}green{{ end }}",
+			},
+			"empty": {
+				Text: "{{ .missing | default \"\" }}",
+			},
+			"deploy": {
+				Text:    "deployed {{ .version }} in {{ .duration }}s{{ .missing }}{{ if .note }}, {{ .note }}{{ end }}",
+				Channel: "{{ .team }}",
+			},
+		},
+	})
+	defer stop()
+
+	tcs := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+		expected     sender.Message
+	}{
+		{
+			name:         "failed build",
+			path:         "/hooks/jenkins",
+			body:         `{"name": "backend", "build": {"number": 42, "status": "FAILURE"}}`,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:        "build 42 of backend: failure",
+				Destination: "ci",
+				Color:       "red",
+			},
+		},
+		{
+			name:         "channel from payload",
+			path:         "/hooks/jenkins",
+			body:         `{"name": "backend", "channel": "dev", "build": {"number": 43, "status": "SUCCESS"}}`,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:        "build 43 of backend: success",
+				Destination: "dev",
+				Color:       "green",
+			},
+		},
+		{
+			name:         "large numbers and missing fields",
+			path:         "/hooks/deploy",
+			body:         `{"team": "ops", "version": 12345678, "duration": 1.5}`,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:        "deployed 12345678 in 1.5s",
+				Destination: "ops",
+			},
+		},
+		{
+			name:         "payload containing <no value>",
+			path:         "/hooks/deploy",
+			body:         `{"team": "ops", "version": 2, "duration": 3, "note": "<no value> is kept"}`,
+			expectedCode: http.StatusAccepted,
+			expected: sender.Message{
+				Text:        "deployed 2 in 3s, <no value> is kept",
+				Destination: "ops",
+			},
+		},
+		{
+			name:         "missing channel",
+			path:         "/hooks/deploy",
+			body:         `{"version": 1}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "body too large",
+			path:         "/hooks/deploy",
+			body:         `{"team": "ops", "version": "` + strings.Repeat("1", 11<<20) + `"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown hook",
+			path:         "/hooks/unknown",
+			body:         `{}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "empty text",
+			path:         "/hooks/empty",
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Post("http://localhost:"+strconv.Itoa(port)+tc.path, "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("wrong status code: got %d, expected %d", resp.StatusCode, tc.expectedCode)
+			}
+			if tc.expectedCode != http.StatusAccepted {
+				return
+			}
+
+			got := dummySender.Last
+			if got.Text != tc.expected.Text || got.Destination != tc.expected.Destination || got.Color != tc.expected.Color {
+				t.Errorf("unexpected message, got: %+v expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNewServerInvalidHook(t *testing.T) {
+	_, err := daemon.NewServer(&config.DaemonConfig{
+		ListenUrl: ":1234",
+		Hooks: map[string]config.Hook{
+			"broken": {Text: "{{ .unclosed "},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "hook \"broken\"") {
+		t.Errorf("expected hook template error, got: %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package daemon
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// time after which a pending multiline entry is considered complete
+const multilineTimeout = 1 * time.Second
+
+// maximum amount of bytes read at once from a log file, larger appends are read in several chunks
+const maxLogRead = 1 << 20
+
+// LogWatcher tails log files and sends the entries matching the configured patterns
+type LogWatcher struct {
+	MsgSender sender.MessageSender
+	watcher   *fsnotify.Watcher
+	files     map[string]*logFile
+	running   int32
+	done      chan interface{}
+	stopOnce  sync.Once
+}
+
+// logFile keeps the tailing state of a watched file
+type logFile struct {
+	mutex     sync.Mutex
+	path      string
+	channel   string
+	multiline *regexp.Regexp
+	patterns  []*logPattern
+	info      os.FileInfo // used to detect rotation
+	offset    int64
+	partial   string   // last line not terminated by a new line
+	entry     []string // lines of the entry being grouped
+	entryTime time.Time
+}
+
+type logPattern struct {
+	match      *regexp.Regexp
+	channel    string
+	color      string
+	severity   string
+	cooldown   time.Duration
+	last       time.Time
+	suppressed int
+}
+
+func NewLogWatcher(cfg *config.DaemonConfig) (*LogWatcher, error) {
+
+	if len(cfg.LogWatch) == 0 {
+		return nil, fmt.Errorf("no log files to watch")
+	}
+
+	files := map[string]*logFile{}
+	for _, c := range cfg.LogWatch {
+		lf, err := newLogFile(c)
+		if err != nil {
+			return nil, err
+		}
+		files[lf.path] = lf
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	sndr, err := newMessageSender(cfg, cfg.DefChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	lw := LogWatcher{
+		MsgSender: sndr,
+		watcher:   watcher,
+		files:     files,
+		done:      make(chan interface{}),
+	}
+	return &lw, nil
+}
+
+// newLogFile validates the configuration of a watched file
+func newLogFile(c config.LogWatch) (*logFile, error) {
+
+	if c.Path == "" {
+		return nil, fmt.Errorf("logwatch: path cannot be empty")
+	}
+	path, err := filepath.Abs(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Patterns) == 0 {
+		return nil, fmt.Errorf("logwatch \"%s\": at least one pattern is needed", c.Path)
+	}
+
+	lf := logFile{
+		path:    path,
+		channel: c.Channel,
+	}
+	if c.Multiline != "" {
+		lf.multiline, err = regexp.Compile(c.Multiline)
+		if err != nil {
+			return nil, fmt.Errorf("logwatch \"%s\": multiline: %v", c.Path, err)
+		}
+	}
+	for _, p := range c.Patterns {
+		match, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, fmt.Errorf("logwatch \"%s\": %v", c.Path, err)
+		}
+		if !sender.IsValidSeverity(p.Severity) {
+			return nil, fmt.Errorf("logwatch \"%s\": %s", c.Path, sender.InvalidSeverityError)
+		}
+		lf.patterns = append(lf.patterns, &logPattern{
+			match:    match,
+			channel:  p.Channel,
+			color:    p.Color,
+			severity: p.Severity,
+			cooldown: p.Cooldown,
+		})
+	}
+	return &lf, nil
+}
+
+// returns true if the watcher is currently running
+func (lw *LogWatcher) IsRunning() bool {
+	if atomic.LoadInt32(&lw.running) == 0 {
+		return false
+	} else {
+		return true
+	}
+}
+
+func (lw *LogWatcher) Start() {
+	if atomic.CompareAndSwapInt32(&lw.running, 0, 1) {
+
+		// only new lines are sent, existing content is skipped
+		dirs := map[string]bool{}
+		for path, lf := range lw.files {
+			log.Info("Starting log watcher on file: " + path)
+			if info, err := os.Stat(path); err == nil {
+				lf.info = info
+				lf.offset = info.Size()
+			}
+			dirs[filepath.Dir(path)] = true
+		}
+
+		// watch the directories to get notified when files are rotated
+		for dir := range dirs {
+			err := lw.watcher.Add(dir)
+			if err != nil {
+				log.Errorf("unable to watch log directory %s: %v", dir, err)
+			}
+		}
+
+		ticker := time.NewTicker(multilineTimeout / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-lw.watcher.Events:
+				if !ok {
+					return
+				}
+				lf, watched := lw.files[event.Name]
+				if watched && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					for more := true; more; {
+						var lines []string
+						lines, more = lf.read()
+						lw.process(lf, lines, false)
+					}
+				}
+
+			case err, ok := <-lw.watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("error:", err)
+
+			case <-ticker.C:
+				// complete the multiline entries that did not get new lines
+				for _, lf := range lw.files {
+					lw.process(lf, nil, true)
+				}
+
+			case <-lw.done:
+				return
+			}
+		}
+	}
+}
+
+// Start the watcher in a non blocking way in a separate routine
+func (lw *LogWatcher) StartBackground() {
+	if atomic.LoadInt32(&lw.running) == 0 {
+		go func() {
+			lw.Start()
+		}()
+	}
+}
+
+// Stop the watcher, calling it more than once has no effect
+func (lw *LogWatcher) Stop() {
+	if atomic.LoadInt32(&lw.running) != 0 {
+		lw.stopOnce.Do(func() {
+			close(lw.done)
+			lw.watcher.Close()
+		})
+	}
+}
+
+// read returns the complete lines appended to the file since the last read, at most maxLogRead bytes,
+// more is true if the file has more data to read. if the file was rotated or truncated it is read from the beginning
+func (lf *logFile) read() (lines []string, more bool) {
+
+	lf.mutex.Lock()
+	defer lf.mutex.Unlock()
+
+	f, err := os.Open(lf.path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false
+	}
+	if lf.info == nil || !os.SameFile(lf.info, info) || info.Size() < lf.offset {
+		lf.offset = 0
+		lf.partial = ""
+	}
+	lf.info = info
+
+	_, err = f.Seek(lf.offset, 0)
+	if err != nil {
+		return nil, false
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxLogRead))
+	if err != nil {
+		return nil, false
+	}
+	lf.offset += int64(len(data))
+
+	lines = strings.Split(lf.partial+string(data), "\n")
+	lf.partial = lines[len(lines)-1]
+	return lines[:len(lines)-1], len(data) == maxLogRead
+}
+
+// process groups the lines into entries and sends the entries matching a pattern,
+// with flush set, pending multiline entries without new lines during the timeout are completed
+func (lw *LogWatcher) process(lf *logFile, lines []string, flush bool) {
+
+	var entries []string
+
+	lf.mutex.Lock()
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if lf.multiline == nil {
+			entries = append(entries, line)
+			continue
+		}
+		if len(lf.entry) > 0 && lf.multiline.MatchString(line) {
+			lf.entry = append(lf.entry, line)
+			lf.entryTime = time.Now()
+			continue
+		}
+		if len(lf.entry) > 0 {
+			entries = append(entries, strings.Join(lf.entry, "\n"))
+		}
+		lf.entry = []string{line}
+		lf.entryTime = time.Now()
+	}
+	if flush && len(lf.entry) > 0 && time.Since(lf.entryTime) >= multilineTimeout {
+		entries = append(entries, strings.Join(lf.entry, "\n"))
+		lf.entry = nil
+	}
+	lf.mutex.Unlock()
+
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		msg := lf.match(entry)
+		if msg == nil {
+			continue
+		}
+		err := lw.MsgSender.SendMessage(msg)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// match returns the message for the first pattern matching the entry, returns nil if no pattern matches
+// or the pattern is in cooldown
+func (lf *logFile) match(entry string) *sender.Message {
+
+	lf.mutex.Lock()
+	defer lf.mutex.Unlock()
+
+	for _, p := range lf.patterns {
+		if !p.match.MatchString(entry) {
+			continue
+		}
+		if p.cooldown > 0 && time.Since(p.last) < p.cooldown {
+			p.suppressed++
+			return nil
+		}
+		p.last = time.Now()
+
+		text := "*[" + filepath.Base(lf.path) + "]*\n```" + entry + "```"
+		if p.suppressed > 0 {
+			text += "\n_" + strconv.Itoa(p.suppressed) + " similar entries suppressed_"
+			p.suppressed = 0
+		}
+
+		msg := sender.Message{
+			Text:        text,
+			Destination: lf.channel,
+			Color:       p.color,
+			Severity:    p.severity,
+		}
+		if p.channel != "" {
+			msg.Destination = p.channel
+		}
+		return &msg
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package daemon_test
+
+import (
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendToFile(t *testing.T, file string, content string) {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogWatcher(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := dir + "/app.log"
+	appendToFile(t, file, "ERROR existing content is not sent\n")
+
+	lw, err := daemon.NewLogWatcher(&config.DaemonConfig{
+		LogWatch: []config.LogWatch{
+			{
+				Path:      file,
+				Channel:   "app",
+				Multiline: `^\s`,
+				Patterns: []config.LogPattern{
+					{Match: "PANIC", Channel: "ops", Severity: "critical"},
+					{Match: "ERROR", Color: "red", Cooldown: time.Hour},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dummySender := sender.DummyMessageSender{}
+	lw.MsgSender = &dummySender
+
+	lw.StartBackground()
+	defer lw.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	t.Run("matching line", func(t *testing.T) {
+		appendToFile(t, file, "INFO all good\nERROR connection refused\n")
+		time.Sleep(50 * time.Millisecond)
+		appendToFile(t, file, "INFO next entry\n")
+
+		expected := sender.Message{
+			Text:        "*[app.log]*\n```ERROR connection refused```",
+			Destination: "app",
+			Color:       "red",
+		}
+		got, _ := dummySender.Next(2 * time.Second)
+		if got.Text != expected.Text || got.Destination != expected.Destination || got.Color != expected.Color {
+			t.Errorf("unexpected message, got: %+v expected: %+v", got, expected)
+		}
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		appendToFile(t, file, "ERROR again\nINFO next entry\n")
+
+		if got, sent := dummySender.Next(200 * time.Millisecond); sent {
+			t.Errorf("expected no message during cooldown, got: %+v", got)
+		}
+	})
+
+	t.Run("multiline entry after rotation", func(t *testing.T) {
+		err := os.Rename(file, file+".1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendToFile(t, file, "PANIC nil pointer\n  at main.go:12\n  at run.go:3\n")
+
+		// the entry is completed after the multiline timeout
+
+		expected := sender.Message{
+			Text:        "*[app.log]*\n```PANIC nil pointer\n  at main.go:12\n  at run.go:3```",
+			Destination: "ops",
+			Severity:    sender.SeverityCritical,
+		}
+		got, _ := dummySender.Next(3 * time.Second)
+		if got.Text != expected.Text || got.Destination != expected.Destination || got.Severity != expected.Severity {
+			t.Errorf("unexpected message, got: %+v expected: %+v", got, expected)
+		}
+	})
+
+	t.Run("burst larger than a single read", func(t *testing.T) {
+		burst := strings.Repeat("INFO "+strings.Repeat("x", 75)+"\n", 20000)
+		appendToFile(t, file, burst+"PANIC after the burst\nINFO next entry\n")
+
+		got, _ := dummySender.Next(3 * time.Second)
+		if got.Text != "*[app.log]*\n```PANIC after the burst```" {
+			t.Errorf("expected the entry after the burst to be sent, got: %+v", got)
+		}
+	})
+
+	t.Run("stop twice", func(t *testing.T) {
+		lw.Stop()
+		lw.Stop()
+	})
+}
+
+func TestNewLogWatcherInvalidConfig(t *testing.T) {
+	tcs := []struct {
+		name        string
+		cfg         config.LogWatch
+		expectedErr string
+	}{
+		{
+			name:        "no patterns",
+			cfg:         config.LogWatch{Path: "/var/log/app.log"},
+			expectedErr: "logwatch \"/var/log/app.log\": at least one pattern is needed",
+		},
+		{
+			name:        "invalid regex",
+			cfg:         config.LogWatch{Path: "/var/log/app.log", Patterns: []config.LogPattern{{Match: "("}}},
+			expectedErr: "logwatch \"/var/log/app.log\": error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := daemon.NewLogWatcher(&config.DaemonConfig{LogWatch: []config.LogWatch{tc.cfg}})
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Errorf("unexpected error, got \"%v\" expected \"%s\"", err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"send2slack/internal/config"
 	"send2slack/internal/mbox"
 	"send2slack/internal/sender"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -82,6 +83,7 @@ type DirWatcher struct {
 	running        int32
 	filesConsuming *itemList
 	throttling     int
+	mboxUsers      map[string]string
 }
 
 func NewDirWatcher(cfg *config.DaemonConfig) (*DirWatcher, error) {
@@ -95,14 +97,7 @@ func NewDirWatcher(cfg *config.DaemonConfig) (*DirWatcher, error) {
 		return nil, err
 	}
 
-	senderCfg := &config.ClientConfig{
-		Token:      cfg.Token,
-		IsDefault:  cfg.IsDefault,
-		DefChannel: cfg.SendmailChannel,
-		Mode:       config.ModeDirectCli,
-	}
-
-	sndr, err := sender.NewSlackSender(senderCfg)
+	sndr, err := newMessageSender(cfg, cfg.SendmailChannel)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +108,7 @@ func NewDirWatcher(cfg *config.DaemonConfig) (*DirWatcher, error) {
 		filesConsuming: newItemList(),
 		MsgSender:      sndr,
 		throttling:     cfg.MailThrottling,
+		mboxUsers:      cfg.MboxUsers,
 	}
 	return &dw, nil
 }
@@ -247,6 +243,11 @@ func (dw *DirWatcher) ConsumeMbox(file string, blockExec bool) {
 					log.Error(err)
 				}
 
+				// route the mail to the slack destination of the mbox owner, unless set in the headers
+				if msg.Destination == "" {
+					msg.Destination = dw.mboxDestination(file)
+				}
+
 				err = dw.MsgSender.SendMessage(msg)
 				if err != nil {
 					log.Error(err)
@@ -266,6 +267,13 @@ func (dw *DirWatcher) ConsumeMbox(file string, blockExec bool) {
 	}
 }
 
+// mboxDestination returns the slack destination mapped to the mbox file, the file name of an mbox
+// is the name of the local unix user, returns empty string if the user is not mapped
+func (dw *DirWatcher) mboxDestination(file string) string {
+	user := strings.ToLower(filepath.Base(file))
+	return dw.mboxUsers[user]
+}
+
 func here() {
 	fmt.Println("=>> HERE")
 }
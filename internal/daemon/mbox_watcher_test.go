@@ -232,6 +232,50 @@ func TestDirWatcher_ConsumeMboxDir(t *testing.T) {
 	})
 }
 
+func TestDirWatcher_MboxUsers(t *testing.T) {
+	// don't print log messages during tests
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DaemonConfig{
+		WatchDir: dir,
+		MboxUsers: map[string]string{
+			"alice": "user:alice@example.com",
+		},
+	}
+	dw, err := daemon.NewDirWatcher(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dummySender := sender.DummyMessageSender{}
+	dw.MsgSender = &dummySender
+
+	t.Run("mapped user", func(t *testing.T) {
+		writeMailToMbox(dir+"/alice", "msg1")
+		dw.ConsumeMboxDir()
+
+		expected := "user:alice@example.com"
+		if dummySender.Last.Destination != expected {
+			t.Errorf("unexpected destination, got \"%s\" expected: \"%s\"", dummySender.Last.Destination, expected)
+		}
+	})
+
+	t.Run("unmapped user", func(t *testing.T) {
+		writeMailToMbox(dir+"/bob", "msg2")
+		dw.ConsumeMboxDir()
+
+		if dummySender.Last.Destination != "" {
+			t.Errorf("expected empty destination, got \"%s\"", dummySender.Last.Destination)
+		}
+	})
+}
+
 func writeMailToMbox(file string, body string) error {
 
 	m :=
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"send2slack/internal/sender"
+	"strconv"
+)
+
+type peerUserKey struct{}
+
+// peerContext stores the user of the process connected to the unix socket in the connection context,
+// it is used as http.Server.ConnContext. with tls the credentials are taken from the socket below the tls
+// connection
+func peerContext(ctx context.Context, c net.Conn) context.Context {
+
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	uid, err := peerUid(uc)
+	if err != nil {
+		log.Debugf("unable to get the peer credentials: %v", err)
+		return ctx
+	}
+
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	return context.WithValue(ctx, peerUserKey{}, name)
+}
+
+// setMessageSource fills the host and the user of a received message, for unix socket connections the user
+// is taken from the socket credentials and overwrites the one reported by the client.
+// the thread key is prefixed with the identity of the client, the user of the socket or the remote address, so
+// that clients cannot reply to or update the threads of the inputs of the server or of other clients
+func setMessageSource(r *http.Request, msg *sender.Message) {
+
+	var identity string
+	if name, ok := r.Context().Value(peerUserKey{}).(string); ok {
+		msg.User = name
+		if msg.Host == "" {
+			msg.Host, _ = os.Hostname()
+		}
+		identity = "user:" + name
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if msg.Host == "" {
+			msg.Host = host
+		}
+		identity = "addr:" + host
+	} else {
+		identity = "addr:" + r.RemoteAddr
+	}
+
+	if msg.ThreadKey != "" {
+		msg.ThreadKey = "client:" + identity + ":" + msg.ThreadKey
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package daemon
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerUid returns the uid of the process connected to the unix socket using SO_PEERCRED
+func peerUid(conn *net.UnixConn) (int, error) {
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return int(cred.Uid), nil
+}
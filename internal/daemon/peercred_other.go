@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+import (
+	"errors"
+	"net"
+)
+
+// peerUid is only supported on linux, on other platforms the user reported by the client is used
+func peerUid(conn *net.UnixConn) (int, error) {
+	return -1, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"time"
+)
+
+// interval in which spooled messages are sent to the upstream server
+const relayFlushInterval = 30 * time.Second
+
+// newMessageSender returns the sender used by the daemon components, in relay mode the messages are
+// forwarded to the upstream server instead of slack
+func newMessageSender(cfg *config.DaemonConfig, defChannel string) (sender.MessageSender, error) {
+
+	senderCfg := &config.ClientConfig{
+		Token:           cfg.Token,
+		IsDefault:       cfg.IsDefault,
+		DefChannel:      defChannel,
+		FallbackChannel: cfg.FallbackChannel,
+		Severities:      cfg.Severities,
+		UploadOverflow:  cfg.UploadOverflow,
+		Mode:            config.ModeDirectCli,
+	}
+
+	if cfg.Relay.Url == "" {
+		sndr, err := sender.NewSlackSender(senderCfg)
+		if err != nil {
+			return nil, err
+		}
+		return sndr, nil
+	}
+
+	u, err := config.ParseRemoteUrl(cfg.Relay.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay url: %v", err)
+	}
+	senderCfg.Url = u
+	senderCfg.TLS = cfg.Relay.TLS
+
+	relay, err := sender.NewRelaySender(senderCfg, cfg.Relay.Spool)
+	if err != nil {
+		return nil, err
+	}
+	return relay, nil
+}
+
+// flushRelaySpool periodically sends the spooled messages to the upstream server until done is closed
+func flushRelaySpool(cfg *config.DaemonConfig, done chan interface{}) {
+
+	sndr, err := newMessageSender(cfg, "")
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	relay := sndr.(*sender.RelaySender)
+
+	ticker := time.NewTicker(relayFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pending, err := relay.Flush()
+			if err != nil {
+				log.Warnf("upstream not reachable, %d messages spooled: %v", pending, err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
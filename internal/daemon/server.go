@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"net/http"
 	"send2slack/internal/config"
 	"send2slack/internal/sender"
@@ -15,49 +16,91 @@ import (
 
 type Server struct {
 	listen      string
+	socket      string // path of the unix socket, empty if listening on tcp
+	socketPerms *socketPerms
 	sever       *http.Server
-	slackSender sender.MessageSender
+	MsgSender   sender.MessageSender
 	running     int32
+	hooks       map[string]*hook
+	github      config.GitWebhook
+	gitlab      config.GitWebhook
 }
 
 func NewServer(cfg *config.DaemonConfig) (*Server, error) {
 
-	host, port, err := ParseListenAddress(cfg.ListenUrl)
+	var listen string
+	socket, isSocket := socketPath(cfg.ListenUrl)
+	if isSocket {
+		if socket == "" {
+			return nil, fmt.Errorf("unable to validate listen url: expecting unix://<path>")
+		}
+		listen = cfg.ListenUrl
+	} else {
+		host, port, err := ParseListenAddress(cfg.ListenUrl)
+		if err != nil {
+			return nil, fmt.Errorf("unable to validate listen url: %v", err)
+		}
+
+		if port == 0 {
+			port = config.DefaultPort
+		}
+		listen = host + ":" + strconv.Itoa(port)
+	}
+
+	perms, err := newSocketPerms(cfg.Socket)
 	if err != nil {
-		return nil, fmt.Errorf("unable to validate listen url: %v", err)
+		return nil, err
 	}
 
-	if port == 0 {
-		port = config.DefaultPort
+	tlsCfg, err := newServerTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.Token == "" {
+	if cfg.Token == "" && cfg.Relay.Url == "" {
 		log.Warn("Token is not defined, the server will not be able to send messages")
 	}
 
-	senderCfg := &config.ClientConfig{
-		Token:      cfg.Token,
-		IsDefault:  cfg.IsDefault,
-		DefChannel: cfg.DefChannel,
-		Mode:       config.ModeDirectCli,
+	sndr, err := newMessageSender(cfg, cfg.DefChannel)
+	if err != nil {
+		return nil, err
 	}
 
-	sender, err := sender.NewSlackSender(senderCfg)
+	hooks, err := newHooks(cfg.Hooks)
 	if err != nil {
 		return nil, err
 	}
 
 	srv := Server{
-		listen:      host + ":" + strconv.Itoa(port),
-		slackSender: sender,
+		listen:      listen,
+		socket:      socket,
+		socketPerms: perms,
+		MsgSender:   sndr,
+		hooks:       hooks,
+		github:      cfg.Github,
+		gitlab:      cfg.Gitlab,
 	}
 
 	httpServer := &http.Server{
-		Addr: srv.listen,
+		TLSConfig: tlsCfg,
+	}
+	if isSocket {
+		httpServer.ConnContext = peerContext
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", srv.mainHandlerFunc)
+	mux.HandleFunc(sender.UploadPath, srv.uploadHandlerFunc)
+	mux.HandleFunc("/alertmanager", srv.alertmanagerHandlerFunc)
+	mux.HandleFunc("/grafana", srv.grafanaHandlerFunc)
+	mux.HandleFunc(hooksPath, srv.hooksHandlerFunc)
+	// the git receivers are only enabled if a secret is configured
+	if srv.github.Secret != "" {
+		mux.HandleFunc("/github", srv.githubHandlerFunc)
+	}
+	if srv.gitlab.Secret != "" {
+		mux.HandleFunc("/gitlab", srv.gitlabHandlerFunc)
+	}
 	httpServer.Handler = mux
 
 	srv.sever = httpServer
@@ -75,8 +118,28 @@ func (srv *Server) IsRunning() bool {
 
 func (srv *Server) Start() {
 	if atomic.CompareAndSwapInt32(&srv.running, 0, 1) {
-		log.Info("Starting Slack server on " + srv.listen)
-		if err := srv.sever.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if srv.sever.TLSConfig != nil {
+			log.Info("Starting Slack server with tls on " + srv.listen)
+		} else {
+			log.Info("Starting Slack server on " + srv.listen)
+		}
+
+		var l net.Listener
+		var err error
+		if srv.socket != "" {
+			l, err = listenUnix(srv.socket, srv.socketPerms)
+		} else {
+			l, err = net.Listen("tcp", srv.listen)
+		}
+		if err == nil {
+			if srv.sever.TLSConfig != nil {
+				// the certificate is provided by the TLSConfig
+				err = srv.sever.ServeTLS(l, "", "")
+			} else {
+				err = srv.sever.Serve(l)
+			}
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}
@@ -123,8 +186,15 @@ func (srv *Server) mainHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "400: error decoding json body")
 		return
 	}
+	setMessageSource(r, &msg)
+
+	srv.deliverMessage(w, &msg)
+}
+
+// deliverMessage validates and sends a message, writing the http response accordingly
+func (srv *Server) deliverMessage(w http.ResponseWriter, msg *sender.Message) {
 
-	err = msg.Validate()
+	err := msg.Validate()
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, "400: error validating message")
@@ -139,9 +209,21 @@ func (srv *Server) mainHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sender := srv.slackSender
-
-	err = sender.SendMessage(&msg)
+	err = srv.MsgSender.SendMessage(msg)
+	// the client must not send the message again if slack refused it
+	if sender.IsRejected(err) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintf(w, "422: slack rejected the message: %s", strings.TrimSpace(err.Error()))
+		log.Warnf("message rejected: %v", err)
+		return
+	}
+	// relays keep the message until the token of the server is fixed
+	if sender.IsAuthError(err) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "503: slack refused the token of the server")
+		log.Errorf("unable to send message: %v", err)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "500: unable to send slack message")
@@ -3,11 +3,17 @@ package daemon_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/phayes/freeport"
 	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"os/user"
 	"send2slack/internal/config"
 	"send2slack/internal/daemon"
 	"send2slack/internal/sender"
@@ -253,3 +259,160 @@ func TestParseListenAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestServerUnixSocket(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := dir + "/send2slack.sock"
+
+	cfg := config.DaemonConfig{
+		ListenUrl: "unix://" + socket,
+		Socket: config.Socket{
+			Mode: "0600",
+		},
+	}
+	srv, err := daemon.NewServer(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummySender := sender.DummyMessageSender{}
+	srv.MsgSender = &dummySender
+	srv.StartBackground()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("socket permissions", func(t *testing.T) {
+		info, err := os.Stat(socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("unexpected socket mode, got: %v expected: %v", info.Mode().Perm(), os.FileMode(0600))
+		}
+	})
+
+	t.Run("send message over the socket", func(t *testing.T) {
+		u, err := url.ParseRequestURI("unix://" + socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode: config.ModeHttpClient,
+			Url:  u,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.SendMessage(&sender.Message{Text: "sample", Debug: true})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("message source", func(t *testing.T) {
+		u, err := url.ParseRequestURI("unix://" + socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode: config.ModeHttpClient,
+			Url:  u,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.SendMessage(&sender.Message{Text: "sample", Origin: sender.OriginEmail, User: "spoofed", ThreadKey: "alertmanager:group"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hostname, _ := os.Hostname()
+		current, _ := user.Current()
+		got := dummySender.Last
+		if got.Origin != sender.OriginEmail || got.Host != hostname || got.User != current.Username {
+			t.Errorf("unexpected message source, got origin: \"%s\" host: \"%s\" user: \"%s\"", got.Origin, got.Host, got.User)
+		}
+		// clients cannot use the thread keys of the inputs of the server
+		if expected := "client:user:" + current.Username + ":alertmanager:group"; got.ThreadKey != expected {
+			t.Errorf("unexpected thread key, got \"%s\" expected \"%s\"", got.ThreadKey, expected)
+		}
+	})
+
+	srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("socket is removed", func(t *testing.T) {
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Errorf("expected socket to be removed, got: %v", err)
+		}
+	})
+}
+
+func TestServerInvalidSocketMode(t *testing.T) {
+	_, err := daemon.NewServer(&config.DaemonConfig{
+		ListenUrl: "unix:///tmp/send2slack.sock",
+		Socket:    config.Socket{Mode: "rw"},
+	})
+	expected := "socket mode: expecting octal permissions like \"0660\", got: \"rw\""
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error, got: \"%v\" expected: \"%s\"", err, expected)
+	}
+}
+
+// rejectingSender fails like the slack api for channels that do not exist, or with a revoked token
+// for the destination "revoked"
+type rejectingSender struct{}
+
+func (s rejectingSender) SendMessage(msg *sender.Message) error {
+	if msg.Destination == "revoked" {
+		return fmt.Errorf("error sending slack message: %w", slack.SlackErrorResponse{Err: "token_revoked"})
+	}
+	return fmt.Errorf("error sending slack message: %w", slack.SlackErrorResponse{Err: "channel_not_found"})
+}
+
+func (s rejectingSender) SendError(err error) {}
+
+func TestServerRejectedMessage(t *testing.T) {
+
+	logrus.SetLevel(logrus.FatalLevel)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := daemon.NewServer(&config.DaemonConfig{ListenUrl: "127.0.0.1:" + strconv.Itoa(port)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.MsgSender = rejectingSender{}
+	srv.StartBackground()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	u, _ := url.Parse("http://127.0.0.1:" + strconv.Itoa(port))
+	client, err := sender.NewSlackSender(&config.ClientConfig{Mode: config.ModeHttpClient, Url: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.SendMessage(&sender.Message{Text: "text", Destination: "missing"})
+	if !sender.IsRejected(err) {
+		t.Fatalf("expected the message to be rejected, got: %v", err)
+	}
+	expected := "message not submitted: 422: slack rejected the message: error sending slack message: channel_not_found"
+	if err.Error() != expected {
+		t.Errorf("unexpected error, got \"%v\" expected \"%s\"", err, expected)
+	}
+
+	// a token error of the server is not a rejection, the client can send the message again later
+	err = client.SendMessage(&sender.Message{Text: "text", Destination: "revoked"})
+	var submitErr *sender.SubmitError
+	if sender.IsRejected(err) || !errors.As(err, &submitErr) || submitErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 for a revoked token, got: %v", err)
+	}
+}
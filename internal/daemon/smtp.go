@@ -0,0 +1,397 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maximum size of a mail received over smtp
+const maxMailSize = 10 << 20
+
+// maximum amount of recipients of a single mail
+const maxRecipients = 100
+
+// time a client can stay idle before the connection is closed
+const smtpTimeout = 5 * time.Minute
+
+// SmtpServer is a minimal smtp server that sends the received mails to slack
+type SmtpServer struct {
+	listen     string
+	MsgSender  sender.MessageSender
+	username   string
+	password   string
+	recipients map[string]string
+	running    int32
+	listener   net.Listener
+}
+
+func NewSmtpServer(cfg *config.DaemonConfig) (*SmtpServer, error) {
+
+	if cfg.Smtp.Listen == "" {
+		return nil, fmt.Errorf("smtp listen address cannot be empty")
+	}
+	if cfg.Smtp.Username != "" && cfg.Smtp.Password == "" {
+		return nil, fmt.Errorf("smtp password cannot be empty if a username is set")
+	}
+
+	sndr, err := newMessageSender(cfg, cfg.SendmailChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := map[string]string{}
+	for k, v := range cfg.Smtp.Recipients {
+		recipients[strings.ToLower(k)] = v
+	}
+
+	s := SmtpServer{
+		listen:     cfg.Smtp.Listen,
+		MsgSender:  sndr,
+		username:   cfg.Smtp.Username,
+		password:   cfg.Smtp.Password,
+		recipients: recipients,
+	}
+	return &s, nil
+}
+
+// returns true if the server is currently running
+func (s *SmtpServer) IsRunning() bool {
+	if atomic.LoadInt32(&s.running) == 0 {
+		return false
+	} else {
+		return true
+	}
+}
+
+func (s *SmtpServer) Start() {
+	if atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		log.Info("Starting smtp server on " + s.listen)
+
+		var err error
+		s.listener, err = net.Listen("tcp", s.listen)
+		if err != nil {
+			log.Fatalf("smtp listen: %s\n", err)
+		}
+
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn)
+		}
+	}
+}
+
+// Start the server in a non blocking way in a separate routine
+func (s *SmtpServer) StartBackground() {
+	if atomic.LoadInt32(&s.running) == 0 {
+		go func() {
+			s.Start()
+		}()
+	}
+}
+
+func (s *SmtpServer) Stop() {
+	if atomic.LoadInt32(&s.running) != 0 && s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// smtpSession holds the state of a single client connection
+type smtpSession struct {
+	conn          net.Conn
+	text          *textproto.Conn
+	authenticated bool
+	mail          bool // true after MAIL FROM, the sender can be empty for bounces
+	from          string
+	rcpts         []string
+}
+
+func (ss *smtpSession) reply(code int, msg string) {
+	ss.text.PrintfLine("%d %s", code, msg)
+}
+
+// handleConn runs the smtp dialog with a client, supported commands are HELO, EHLO, AUTH (PLAIN and LOGIN),
+// MAIL, RCPT, DATA, RSET, NOOP, VRFY and QUIT
+func (s *SmtpServer) handleConn(conn net.Conn) {
+
+	ss := smtpSession{
+		conn:          conn,
+		text:          textproto.NewConn(conn),
+		authenticated: s.username == "",
+	}
+	defer ss.text.Close()
+
+	ss.reply(220, "send2slack ESMTP ready")
+	for {
+		conn.SetDeadline(time.Now().Add(smtpTimeout))
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg := line, ""
+		if i := strings.Index(line, " "); i > 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(cmd) {
+		case "HELO":
+			ss.reset()
+			ss.reply(250, "send2slack")
+		case "EHLO":
+			ss.reset()
+			ext := []string{"send2slack", "8BITMIME", fmt.Sprintf("SIZE %d", maxMailSize)}
+			if s.username != "" {
+				ext = append(ext, "AUTH PLAIN LOGIN")
+			}
+			for i, e := range ext {
+				if i == len(ext)-1 {
+					ss.text.PrintfLine("250 %s", e)
+				} else {
+					ss.text.PrintfLine("250-%s", e)
+				}
+			}
+		case "AUTH":
+			s.auth(&ss, arg)
+		case "MAIL":
+			if !ss.authenticated {
+				ss.reply(530, "authentication required")
+				continue
+			}
+			addr, ok := smtpAddress(arg, "FROM:")
+			if !ok {
+				ss.reply(501, "syntax: MAIL FROM:<address>")
+				continue
+			}
+			ss.reset()
+			ss.mail = true
+			ss.from = addr
+			ss.reply(250, "ok")
+		case "RCPT":
+			if !ss.mail {
+				ss.reply(503, "need MAIL before RCPT")
+				continue
+			}
+			addr, ok := smtpAddress(arg, "TO:")
+			if !ok || addr == "" {
+				ss.reply(501, "syntax: RCPT TO:<address>")
+				continue
+			}
+			if len(ss.rcpts) >= maxRecipients {
+				ss.reply(452, "too many recipients")
+				continue
+			}
+			ss.rcpts = append(ss.rcpts, addr)
+			ss.reply(250, "ok")
+		case "DATA":
+			if len(ss.rcpts) == 0 {
+				ss.reply(503, "need RCPT before DATA")
+				continue
+			}
+			s.data(&ss)
+			ss.reset()
+		case "RSET":
+			ss.reset()
+			ss.reply(250, "ok")
+		case "NOOP":
+			ss.reply(250, "ok")
+		case "VRFY":
+			ss.reply(252, "cannot verify user")
+		case "QUIT":
+			ss.reply(221, "bye")
+			return
+		default:
+			ss.reply(502, "command not implemented")
+		}
+	}
+}
+
+// reset clears the current mail transaction
+func (ss *smtpSession) reset() {
+	ss.mail = false
+	ss.from = ""
+	ss.rcpts = nil
+}
+
+// auth handles the AUTH command for the PLAIN and LOGIN mechanisms
+func (s *SmtpServer) auth(ss *smtpSession, arg string) {
+
+	if s.username == "" {
+		ss.reply(502, "authentication not enabled")
+		return
+	}
+	if ss.authenticated {
+		ss.reply(503, "already authenticated")
+		return
+	}
+
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		ss.reply(501, "syntax: AUTH <mechanism>")
+		return
+	}
+
+	var user, pass string
+	switch strings.ToUpper(fields[0]) {
+	case "PLAIN":
+		resp := ""
+		if len(fields) > 1 {
+			resp = fields[1]
+		} else {
+			ss.text.PrintfLine("334 ")
+			resp, _ = ss.text.ReadLine()
+		}
+		decoded, err := base64.StdEncoding.DecodeString(resp)
+		if err != nil {
+			ss.reply(501, "invalid base64 encoding")
+			return
+		}
+		// authorization identity \0 authentication identity \0 password
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) != 3 {
+			ss.reply(501, "invalid PLAIN response")
+			return
+		}
+		user, pass = parts[1], parts[2]
+
+	case "LOGIN":
+		var err error
+		if len(fields) > 1 {
+			user, err = decodeBase64(fields[1])
+		} else {
+			user, err = ss.challenge("Username:")
+		}
+		if err == nil {
+			pass, err = ss.challenge("Password:")
+		}
+		if err != nil {
+			ss.reply(501, "invalid LOGIN response")
+			return
+		}
+
+	default:
+		ss.reply(504, "unrecognized authentication type")
+		return
+	}
+
+	userOk := subtle.ConstantTimeCompare([]byte(user), []byte(s.username)) == 1
+	passOk := subtle.ConstantTimeCompare([]byte(pass), []byte(s.password)) == 1
+	if !userOk || !passOk {
+		log.Warnf("smtp authentication failed for user \"%s\" from %s", user, ss.conn.RemoteAddr())
+		ss.reply(535, "authentication credentials invalid")
+		return
+	}
+	ss.authenticated = true
+	ss.reply(235, "authentication successful")
+}
+
+// challenge sends a base64 encoded prompt and returns the decoded answer of the client
+func (ss *smtpSession) challenge(prompt string) (string, error) {
+	ss.text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+	line, err := ss.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	return decodeBase64(line)
+}
+
+func decodeBase64(in string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(in))
+	return string(b), err
+}
+
+// data reads the mail content and sends it to slack
+func (s *SmtpServer) data(ss *smtpSession) {
+
+	ss.reply(354, "end data with <CR><LF>.<CR><LF>")
+
+	dr := ss.text.DotReader()
+	body, err := ioutil.ReadAll(io.LimitReader(dr, maxMailSize+1))
+	if err != nil {
+		ss.reply(451, "error reading data")
+		return
+	}
+	if len(body) > maxMailSize {
+		io.Copy(ioutil.Discard, dr)
+		ss.reply(552, "message exceeds the maximum size")
+		return
+	}
+
+	msg, err := sender.NewMessageFromMailStr(string(body))
+	if err != nil {
+		log.Error(err)
+		ss.reply(554, "error parsing mail")
+		return
+	}
+
+	// the destination set in the headers takes precedence over the recipients
+	destinations := []string{msg.Destination}
+	if msg.Destination == "" {
+		destinations = s.rcptDestinations(ss.rcpts)
+	}
+
+	for _, dest := range destinations {
+		m := *msg
+		m.Destination = dest
+		err = s.MsgSender.SendMessage(&m)
+		if err != nil {
+			log.Error(err)
+			ss.reply(451, "error sending message")
+			return
+		}
+	}
+	ss.reply(250, "ok")
+}
+
+// rcptDestinations returns the slack destinations mapped to the recipients, a recipient is looked up by
+// the full address first and by the local part afterwards, recipients that are not mapped are sent
+// to the default channel
+func (s *SmtpServer) rcptDestinations(rcpts []string) []string {
+
+	var destinations []string
+	seen := map[string]bool{}
+	for _, rcpt := range rcpts {
+		rcpt = strings.ToLower(rcpt)
+		dest, ok := s.recipients[rcpt]
+		if !ok {
+			dest = s.recipients[strings.SplitN(rcpt, "@", 2)[0]]
+		}
+		if !seen[dest] {
+			seen[dest] = true
+			destinations = append(destinations, dest)
+		}
+	}
+	return destinations
+}
+
+// smtpAddress extracts the address of a MAIL FROM or RCPT TO argument, parameters like SIZE are ignored
+func smtpAddress(arg string, prefix string) (string, bool) {
+	if !strings.HasPrefix(strings.ToUpper(arg), prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if strings.HasPrefix(arg, "<") {
+		end := strings.Index(arg, ">")
+		if end < 0 {
+			return "", false
+		}
+		return arg[1:end], true
+	}
+	if fields := strings.Fields(arg); len(fields) > 0 {
+		return fields[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,115 @@
+package daemon_test
+
+import (
+	"github.com/phayes/freeport"
+	"github.com/sirupsen/logrus"
+	"net/smtp"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSmtpServer(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	srv, err := daemon.NewSmtpServer(&config.DaemonConfig{
+		Smtp: config.Smtp{
+			Listen:   addr,
+			Username: "app",
+			Password: "secret",
+			Recipients: map[string]string{
+				"ops":                "#ops",
+				"backup@example.com": "#backup",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummySender := sender.DummyMessageSender{}
+	srv.MsgSender = &dummySender
+
+	srv.StartBackground()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	auth := smtp.PlainAuth("", "app", "secret", "127.0.0.1")
+
+	tcs := []struct {
+		name        string
+		rcpt        string
+		mail        string
+		expected    sender.Message
+		expectedErr string
+	}{
+		{
+			name:     "recipient local part",
+			rcpt:     "ops@example.com",
+			mail:     "Subject: disk full\r\n\r\n/var is full\r\n",
+			expected: sender.Message{Text: "/var is full\n", Destination: "#ops"},
+		},
+		{
+			name:     "recipient address",
+			rcpt:     "backup@example.com",
+			mail:     "Subject: backup\r\n\r\nbackup done\r\n",
+			expected: sender.Message{Text: "backup done\n", Destination: "#backup"},
+		},
+		{
+			name:     "channel header",
+			rcpt:     "ops@example.com",
+			mail:     "X-Slack-Channel: dev\r\nX-Slack-Color: red\r\n\r\nbuild failed\r\n",
+			expected: sender.Message{Text: "build failed\n", Destination: "dev", Color: "red"},
+		},
+		{
+			name:     "unmapped recipient",
+			rcpt:     "root@localhost",
+			mail:     "Subject: cron\r\n\r\ncron output\r\n",
+			expected: sender.Message{Text: "cron output\n"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dummySender.Last = sender.Message{}
+			err := smtp.SendMail(addr, auth, "app@localhost", []string{tc.rcpt}, []byte(tc.mail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := dummySender.Last
+			if got.Text != tc.expected.Text || got.Destination != tc.expected.Destination || got.Color != tc.expected.Color {
+				t.Errorf("unexpected message, got: %+v expected: %+v", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		auth := smtp.PlainAuth("", "app", "wrong", "127.0.0.1")
+		err := smtp.SendMail(addr, auth, "app@localhost", []string{"ops"}, []byte("\r\ntext\r\n"))
+		if err == nil || !strings.Contains(err.Error(), "535") {
+			t.Errorf("expected authentication error, got: %v", err)
+		}
+	})
+
+	t.Run("authentication required", func(t *testing.T) {
+		c, err := smtp.Dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		err = c.Mail("app@localhost")
+		if err == nil || !strings.Contains(err.Error(), "530") {
+			t.Errorf("expected authentication required error, got: %v", err)
+		}
+	})
+}
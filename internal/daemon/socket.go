@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"send2slack/internal/config"
+	"strconv"
+	"strings"
+)
+
+const unixScheme = "unix://"
+
+// default permissions of the server socket, only the owner and the group can send messages
+const defaultSocketMode = 0660
+
+// socketPerms are the validated ownership and permissions of a unix socket, -1 keeps the current owner or group
+type socketPerms struct {
+	uid  int
+	gid  int
+	mode os.FileMode
+}
+
+// newSocketPerms looks up the configured owner and group and parses the file mode
+func newSocketPerms(cfg config.Socket) (*socketPerms, error) {
+
+	perms := socketPerms{
+		uid:  -1,
+		gid:  -1,
+		mode: defaultSocketMode,
+	}
+
+	if cfg.Owner != "" {
+		uid, err := strconv.Atoi(cfg.Owner)
+		if err != nil {
+			u, err := user.Lookup(cfg.Owner)
+			if err != nil {
+				return nil, fmt.Errorf("socket owner: %v", err)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+		perms.uid = uid
+	}
+
+	if cfg.Group != "" {
+		gid, err := strconv.Atoi(cfg.Group)
+		if err != nil {
+			g, err := user.LookupGroup(cfg.Group)
+			if err != nil {
+				return nil, fmt.Errorf("socket group: %v", err)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+		perms.gid = gid
+	}
+
+	if cfg.Mode != "" {
+		mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil || mode > 0777 {
+			return nil, fmt.Errorf("socket mode: expecting octal permissions like \"0660\", got: \"%s\"", cfg.Mode)
+		}
+		perms.mode = os.FileMode(mode)
+	}
+	return &perms, nil
+}
+
+// listenUnix creates the unix socket and applies the ownership and permissions,
+// a socket left over by a previous run is removed
+func listenUnix(path string, perms *socketPerms) (net.Listener, error) {
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, perms.mode)
+	if err == nil && (perms.uid != -1 || perms.gid != -1) {
+		err = os.Chown(path, perms.uid, perms.gid)
+	}
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("unable to set socket permissions: %v", err)
+	}
+	return l, nil
+}
+
+// socketPath returns the path of a listen url like unix:///run/send2slack.sock, the second value is false
+// if the url is not a unix socket
+func socketPath(listenUrl string) (string, bool) {
+	if !strings.HasPrefix(listenUrl, unixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(listenUrl, unixScheme), true
+}
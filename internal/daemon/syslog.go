@@ -0,0 +1,351 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maximum size of a single syslog message
+const maxSyslogSize = 64 * 1024
+
+// SyslogReceiver listens for syslog messages and forwards the ones matching the filters to slack
+type SyslogReceiver struct {
+	network    string
+	address    string
+	MsgSender  sender.MessageSender
+	filters    []syslogFilter
+	limiter    *rateLimiter
+	running    int32
+	mutex      sync.Mutex // protects packetConn, listener and stopped between Start and Stop
+	packetConn net.PacketConn
+	listener   net.Listener
+	stopped    bool
+	stopOnce   sync.Once
+}
+
+func NewSyslogReceiver(cfg *config.DaemonConfig) (*SyslogReceiver, error) {
+
+	network, address, err := parseSyslogListen(cfg.Syslog.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := newSyslogFilters(cfg.Syslog.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	sndr, err := newMessageSender(cfg, cfg.DefChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	sr := SyslogReceiver{
+		network:   network,
+		address:   address,
+		MsgSender: sndr,
+		filters:   filters,
+		limiter:   newRateLimiter(cfg.Syslog.RateLimit, time.Minute),
+	}
+	return &sr, nil
+}
+
+// parseSyslogListen splits a listen url like udp://127.0.0.1:514 into network and address
+func parseSyslogListen(in string) (string, string, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid syslog listen url: %v", err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return "", "", fmt.Errorf("invalid syslog listen url, expecting %s://<ip>:<port>", u.Scheme)
+		}
+		return u.Scheme, u.Host, nil
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid syslog listen url, expecting unix://<path>")
+		}
+		return "unixgram", u.Path, nil
+	default:
+		return "", "", fmt.Errorf("invalid syslog listen url, expecting udp://, tcp:// or unix:// got: \"%s\"", in)
+	}
+}
+
+// returns true if the receiver is currently running
+func (sr *SyslogReceiver) IsRunning() bool {
+	if atomic.LoadInt32(&sr.running) == 0 {
+		return false
+	} else {
+		return true
+	}
+}
+
+func (sr *SyslogReceiver) Start() {
+	if atomic.CompareAndSwapInt32(&sr.running, 0, 1) {
+		log.Info("Starting syslog receiver on " + sr.network + ":" + sr.address)
+
+		switch sr.network {
+		case "tcp":
+			l, err := net.Listen(sr.network, sr.address)
+			if err != nil {
+				log.Fatalf("syslog listen: %s\n", err)
+			}
+			if !sr.setListeners(l, nil) {
+				return
+			}
+			sr.acceptStreams()
+		default:
+			if sr.network == "unixgram" {
+				// remove a stale socket of a previous run
+				os.Remove(sr.address)
+			}
+			pc, err := net.ListenPacket(sr.network, sr.address)
+			if err != nil {
+				log.Fatalf("syslog listen: %s\n", err)
+			}
+			if !sr.setListeners(nil, pc) {
+				return
+			}
+			sr.readPackets()
+		}
+	}
+}
+
+// setListeners stores the listeners so that Stop can close them, if the receiver was stopped in the meantime
+// they are closed and false is returned
+func (sr *SyslogReceiver) setListeners(l net.Listener, pc net.PacketConn) bool {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.stopped {
+		if l != nil {
+			l.Close()
+		}
+		if pc != nil {
+			pc.Close()
+		}
+		return false
+	}
+	sr.listener = l
+	sr.packetConn = pc
+	return true
+}
+
+// Start the receiver in a non blocking way in a separate routine
+func (sr *SyslogReceiver) StartBackground() {
+	if atomic.LoadInt32(&sr.running) == 0 {
+		go func() {
+			sr.Start()
+		}()
+	}
+}
+
+// Stop the receiver, calling it more than once has no effect
+func (sr *SyslogReceiver) Stop() {
+	if atomic.LoadInt32(&sr.running) != 0 {
+		sr.stopOnce.Do(func() {
+			sr.mutex.Lock()
+			defer sr.mutex.Unlock()
+
+			sr.stopped = true
+			if sr.listener != nil {
+				sr.listener.Close()
+			}
+			if sr.packetConn != nil {
+				sr.packetConn.Close()
+			}
+		})
+	}
+}
+
+// readPackets handles datagram sockets, every packet contains one message
+func (sr *SyslogReceiver) readPackets() {
+	buf := make([]byte, maxSyslogSize)
+	for {
+		n, _, err := sr.packetConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		sr.handle(string(buf[:n]))
+	}
+}
+
+// acceptStreams handles stream connections
+func (sr *SyslogReceiver) acceptStreams() {
+	for {
+		conn, err := sr.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			r := bufio.NewReaderSize(conn, maxSyslogSize)
+			for {
+				line, err := readSyslogFrame(r)
+				if line != "" {
+					sr.handle(line)
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+// readSyslogFrame reads one message of a stream, both octet counting "<len> <msg>" as well as
+// newline delimited framing is supported (RFC 6587)
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(lenStr))
+		if err != nil || length > maxSyslogSize {
+			return "", fmt.Errorf("invalid syslog frame length")
+		}
+		buf := make([]byte, length)
+		_, err = io.ReadFull(r, buf)
+		return string(buf), err
+	}
+
+	line, err := r.ReadString('\n')
+	return strings.TrimSpace(line), err
+}
+
+// handle forwards a received line if it matches the filters and the rate limit allows it
+func (sr *SyslogReceiver) handle(line string) {
+
+	m, err := parseSyslogMessage(line)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	channel, ok := sr.route(m)
+	if !ok {
+		return
+	}
+
+	allowed, dropped := sr.limiter.allow()
+	if !allowed {
+		return
+	}
+	if dropped > 0 {
+		sr.send(&sender.Message{
+			Text:        strconv.Itoa(dropped) + " syslog messages dropped due to the rate limit",
+			Destination: channel,
+			Color:       "orange",
+		})
+	}
+
+	source := strings.TrimSpace(m.hostname + " " + m.program)
+	text := m.text
+	if source != "" {
+		text = "*[" + source + "]* " + text
+	}
+
+	sr.send(&sender.Message{
+		Text:        text,
+		Destination: channel,
+		Severity:    syslogToSeverity(m.severity),
+	})
+}
+
+func (sr *SyslogReceiver) send(msg *sender.Message) {
+	err := sr.MsgSender.SendMessage(msg)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// route returns the channel of the first matching filter, without filters all the messages are forwarded
+// to the default channel, the second value is false if the message should be discarded
+func (sr *SyslogReceiver) route(m *syslogMessage) (string, bool) {
+	if len(sr.filters) == 0 {
+		return "", true
+	}
+	for i := range sr.filters {
+		if sr.filters[i].matches(m) {
+			return sr.filters[i].channel, true
+		}
+	}
+	return "", false
+}
+
+// syslogToSeverity maps the syslog severity to the message severity
+func syslogToSeverity(s int) string {
+	switch {
+	case s <= 2:
+		return sender.SeverityCritical
+	case s == 3:
+		return sender.SeverityError
+	case s == 4:
+		return sender.SeverityWarning
+	case s <= 6:
+		return sender.SeverityInfo
+	default:
+		return ""
+	}
+}
+
+// rateLimiter allows a maximum amount of events per time window
+type rateLimiter struct {
+	mutex   sync.Mutex
+	limit   int
+	window  time.Duration
+	start   time.Time
+	count   int
+	dropped int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+	}
+}
+
+// allow returns true if the event is within the limit, the second value is the amount of events
+// dropped since the last allowed one
+func (l *rateLimiter) allow() (bool, int) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if time.Since(l.start) > l.window {
+		l.start = time.Now()
+		l.count = 0
+	}
+	if l.count >= l.limit {
+		l.dropped++
+		return false, 0
+	}
+	l.count++
+	dropped := l.dropped
+	l.dropped = 0
+	return true, dropped
+}
@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"fmt"
+	"regexp"
+	"send2slack/internal/config"
+	"strconv"
+	"strings"
+)
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5",
+	"local6", "local7",
+}
+
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslogMessage is a parsed RFC 5424 or RFC 3164 message
+type syslogMessage struct {
+	facility int
+	severity int
+	hostname string
+	program  string
+	text     string
+}
+
+// parseSyslogMessage parses a single syslog line in RFC 5424 or RFC 3164 format
+func parseSyslogMessage(line string) (*syslogMessage, error) {
+
+	line = strings.TrimRight(line, "\r\n\x00")
+	if !strings.HasPrefix(line, "<") {
+		return nil, fmt.Errorf("invalid syslog message: missing priority")
+	}
+	end := strings.Index(line, ">")
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid syslog message: invalid priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("invalid syslog message: invalid priority")
+	}
+
+	m := syslogMessage{
+		facility: pri / 8,
+		severity: pri % 8,
+	}
+	rest := line[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		// RFC 5424: VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+		fields := strings.SplitN(rest[2:], " ", 6)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("invalid syslog message: incomplete header")
+		}
+		m.hostname = nilValue(fields[1])
+		m.program = nilValue(fields[2])
+		m.text = skipStructuredData(fields[5])
+		return &m, nil
+	}
+
+	// RFC 3164: TIMESTAMP SP [HOSTNAME SP] TAG MSG, the timestamp has the format "Mmm dd hh:mm:ss"
+	if len(rest) > 16 && rest[15] == ' ' {
+		rest = rest[16:]
+	}
+	fields := strings.SplitN(rest, " ", 2)
+	// messages sent to the local socket don't contain the hostname
+	if len(fields) == 2 && !isSyslogTag(fields[0]) {
+		m.hostname = fields[0]
+		rest = fields[1]
+	}
+	if i := strings.Index(rest, ":"); i > 0 && isSyslogTag(rest[:i+1]) {
+		m.program = rest[:i]
+		rest = rest[i+1:]
+	}
+	if i := strings.Index(m.program, "["); i > 0 {
+		m.program = m.program[:i]
+	}
+	m.text = strings.TrimSpace(rest)
+	return &m, nil
+}
+
+// isSyslogTag returns true if the word is a tag like "sshd:" or "sshd[123]:"
+func isSyslogTag(word string) bool {
+	return strings.HasSuffix(word, ":") && !strings.Contains(word, " ")
+}
+
+// nilValue returns empty string for the RFC 5424 nil value "-"
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// skipStructuredData removes the structured data from the beginning of a RFC 5424 message
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimSpace(strings.TrimPrefix(s, "-"))
+	}
+	if !strings.HasPrefix(s, "[") {
+		return strings.TrimSpace(s)
+	}
+	inValue := false
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inValue:
+			i++
+		case s[i] == '"':
+			inValue = !inValue
+		case s[i] == '[' && !inValue:
+			depth++
+		case s[i] == ']' && !inValue:
+			depth--
+			if depth == 0 && (i+1 == len(s) || s[i+1] != '[') {
+				return strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// syslogFilter is the parsed version of a configured filter
+type syslogFilter struct {
+	facility int // -1 matches all
+	severity int // maximum severity value, 7 (debug) matches all
+	program  string
+	match    *regexp.Regexp
+	channel  string
+}
+
+// newSyslogFilters validates and parses the configured filters
+func newSyslogFilters(cfg []config.SyslogFilter) ([]syslogFilter, error) {
+
+	var filters []syslogFilter
+	for i, c := range cfg {
+		f := syslogFilter{
+			facility: -1,
+			severity: 7,
+			program:  c.Program,
+			channel:  c.Channel,
+		}
+		if c.Facility != "" {
+			f.facility = indexOf(syslogFacilities, c.Facility)
+			if f.facility < 0 {
+				return nil, fmt.Errorf("syslog filter %d: unknown facility \"%s\"", i+1, c.Facility)
+			}
+		}
+		if c.Severity != "" {
+			f.severity = indexOf(syslogSeverities, c.Severity)
+			if f.severity < 0 {
+				return nil, fmt.Errorf("syslog filter %d: unknown severity \"%s\"", i+1, c.Severity)
+			}
+		}
+		if c.Match != "" {
+			var err error
+			f.match, err = regexp.Compile(c.Match)
+			if err != nil {
+				return nil, fmt.Errorf("syslog filter %d: %v", i+1, err)
+			}
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// matches returns true if the message fulfills all the conditions of the filter
+func (f *syslogFilter) matches(m *syslogMessage) bool {
+	if f.facility >= 0 && f.facility != m.facility {
+		return false
+	}
+	if m.severity > f.severity {
+		return false
+	}
+	if f.program != "" && f.program != m.program {
+		return false
+	}
+	if f.match != nil && !f.match.MatchString(m.text) {
+		return false
+	}
+	return true
+}
+
+func indexOf(list []string, s string) int {
+	for i := range list {
+		if list[i] == strings.ToLower(s) {
+			return i
+		}
+	}
+	return -1
+}
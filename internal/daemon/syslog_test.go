@@ -0,0 +1,181 @@
+package daemon_test
+
+import (
+	"github.com/phayes/freeport"
+	"github.com/sirupsen/logrus"
+	"net"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSyslogReceiver(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	sr, err := daemon.NewSyslogReceiver(&config.DaemonConfig{
+		Syslog: config.Syslog{
+			Listen:    "udp://" + addr,
+			RateLimit: 3,
+			Filters: []config.SyslogFilter{
+				{Facility: "auth", Program: "sshd", Match: "Failed password", Channel: "security"},
+				{Severity: "err", Channel: "ops"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummySender := sender.DummyMessageSender{}
+	sr.MsgSender = &dummySender
+
+	sr.StartBackground()
+	defer sr.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tcs := []struct {
+		name     string
+		line     string
+		expected sender.Message
+	}{
+		{
+			name: "rfc3164 auth message",
+			line: "<38>Oct 11 22:14:15 web-03 sshd[1234]: Failed password for root from 10.0.0.1",
+			expected: sender.Message{
+				Text:        "*[web-03 sshd]* Failed password for root from 10.0.0.1",
+				Destination: "security",
+				Severity:    sender.SeverityInfo,
+			},
+		},
+		{
+			name: "rfc5424 error",
+			line: `<11>1 2003-10-11T22:14:15.003Z db-01 postgres 42 ID47 [exampleSDID@32473 iut="3"] disk full`,
+			expected: sender.Message{
+				Text:        "*[db-01 postgres]* disk full",
+				Destination: "ops",
+				Severity:    sender.SeverityError,
+			},
+		},
+		{
+			name: "local socket message without host",
+			line: "<10>Oct 11 22:14:15 kernel: out of memory",
+			expected: sender.Message{
+				Text:        "*[kernel]* out of memory",
+				Destination: "ops",
+				Severity:    sender.SeverityCritical,
+			},
+		},
+		{
+			name: "not matching any filter",
+			line: "<14>Oct 11 22:14:15 web-03 cron[1]: job done",
+		},
+		{
+			name: "rate limited",
+			line: "<11>Oct 11 22:14:15 web-03 app: error",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := conn.Write([]byte(tc.line))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// lines not sent are only detected by waiting a short time
+			timeout := time.Second
+			if tc.expected.Text == "" {
+				timeout = 100 * time.Millisecond
+			}
+			got, _ := dummySender.Next(timeout)
+			if got.Text != tc.expected.Text || got.Destination != tc.expected.Destination || got.Severity != tc.expected.Severity {
+				t.Errorf("unexpected message, got: %+v expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSyslogReceiver_Stop(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := daemon.NewSyslogReceiver(&config.DaemonConfig{
+		Syslog: config.Syslog{Listen: "tcp://127.0.0.1:" + strconv.Itoa(port)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stopping while the receiver starts and stopping twice must not race or panic
+	sr.StartBackground()
+	time.Sleep(10 * time.Millisecond)
+	sr.Stop()
+	sr.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port)); err == nil {
+		t.Error("expected the listener to be closed")
+	}
+}
+
+func TestNewSyslogReceiver(t *testing.T) {
+	tcs := []struct {
+		name        string
+		cfg         config.Syslog
+		expectedErr string
+	}{
+		{
+			name:        "invalid scheme",
+			cfg:         config.Syslog{Listen: "http://127.0.0.1:514"},
+			expectedErr: "invalid syslog listen url, expecting udp://, tcp:// or unix:// got: \"http://127.0.0.1:514\"",
+		},
+		{
+			name:        "unknown facility",
+			cfg:         config.Syslog{Listen: "udp://127.0.0.1:514", Filters: []config.SyslogFilter{{Facility: "nope"}}},
+			expectedErr: "syslog filter 1: unknown facility \"nope\"",
+		},
+		{
+			name:        "unknown severity",
+			cfg:         config.Syslog{Listen: "unix:///tmp/s2s.sock", Filters: []config.SyslogFilter{{Severity: "bad"}}},
+			expectedErr: "syslog filter 1: unknown severity \"bad\"",
+		},
+		{
+			name: "valid tcp",
+			cfg:  config.Syslog{Listen: "tcp://127.0.0.1:514", Filters: []config.SyslogFilter{{Severity: "warning"}}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := daemon.NewSyslogReceiver(&config.DaemonConfig{Syslog: tc.cfg})
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Errorf("unexpected error, got \"%v\" expected \"%s\"", err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"send2slack/internal/config"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate of the server and loads it again when the files are modified,
+// this allows renewing the certificate without restarting the daemon
+type certReloader struct {
+	mutex    sync.Mutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// load reads the certificate and key, the caller must hold the lock or be the only user
+func (r *certReloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load tls certificate: %v", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// lastModified returns the latest modification time of the certificate and the key
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, fmt.Errorf("unable to load tls certificate: %v", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// getCertificate is used as tls.Config.GetCertificate, if reloading fails the previous certificate is kept
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	modTime, err := r.lastModified()
+	if err == nil && !modTime.Equal(r.modTime) {
+		err = r.load()
+		if err != nil {
+			log.Errorf("keeping the previous certificate: %v", err)
+		} else {
+			log.Info("reloaded tls certificate " + r.certFile)
+		}
+	}
+	return r.cert, nil
+}
+
+// newServerTLSConfig returns the tls configuration of the server, nil if tls is not configured
+func newServerTLSConfig(cfg config.ServerTLS) (*tls.Config, error) {
+
+	if cfg.Cert == "" && cfg.Key == "" {
+		if cfg.ClientCA != "" {
+			return nil, fmt.Errorf("tls client_ca requires a server certificate")
+		}
+		return nil, nil
+	}
+	if cfg.Cert == "" || cfg.Key == "" {
+		return nil, fmt.Errorf("tls needs both a certificate and a key")
+	}
+
+	reloader, err := newCertReloader(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if cfg.ClientCA != "" {
+		pem, err := ioutil.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls client_ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls client_ca \"%s\"", cfg.ClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
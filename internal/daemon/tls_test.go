@@ -0,0 +1,279 @@
+package daemon_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/phayes/freeport"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA creates a self signed CA and writes the certificate to <dir>/ca.pem
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "send2slack test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePem(t, dir+"/ca.pem", "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue creates a certificate signed by the CA and writes it to <dir>/<name>.pem and <dir>/<name>-key.pem
+func (ca *testCA) issue(t *testing.T, dir string, name string, serial int64, usage x509.ExtKeyUsage) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePem(t, dir+"/"+name+".pem", "CERTIFICATE", der)
+	writePem(t, dir+"/"+name+"-key.pem", "EC PRIVATE KEY", keyDer)
+}
+
+func writePem(t *testing.T, file string, blockType string, der []byte) {
+	err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+
+	logrus.SetLevel(logrus.FatalLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue(t, dir, "server", 2, x509.ExtKeyUsageServerAuth)
+	ca.issue(t, dir, "client", 3, x509.ExtKeyUsageClientAuth)
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	srv, err := daemon.NewServer(&config.DaemonConfig{
+		ListenUrl: addr,
+		TLS: config.ServerTLS{
+			Cert:     dir + "/server.pem",
+			Key:      dir + "/server-key.pem",
+			ClientCA: dir + "/ca.pem",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.StartBackground()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	u, err := url.ParseRequestURI("https://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("client with certificate", func(t *testing.T) {
+		client, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode: config.ModeHttpClient,
+			Url:  u,
+			TLS: config.ClientTLS{
+				CA:   dir + "/ca.pem",
+				Cert: dir + "/client.pem",
+				Key:  dir + "/client-key.pem",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.SendMessage(&sender.Message{Text: "sample", Debug: true})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("client without certificate", func(t *testing.T) {
+		client, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode: config.ModeHttpClient,
+			Url:  u,
+			TLS:  config.ClientTLS{CA: dir + "/ca.pem"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.SendMessage(&sender.Message{Text: "sample", Debug: true})
+		if err == nil {
+			t.Error("expected the server to reject a client without certificate")
+		}
+	})
+
+	t.Run("certificate reload", func(t *testing.T) {
+		ca.issue(t, dir, "server", 4, x509.ExtKeyUsageServerAuth)
+		// make sure the modification time differs from the first certificate
+		future := time.Now().Add(time.Minute)
+		os.Chtimes(dir+"/server.pem", future, future)
+
+		clientCert, err := tls.LoadX509KeyPair(dir+"/client.pem", dir+"/client-key.pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		got := conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+		if got != 4 {
+			t.Errorf("expected the reloaded certificate with serial 4, got serial %d", got)
+		}
+	})
+}
+
+func TestServerTLSUnixSocket(t *testing.T) {
+
+	logrus.SetLevel(logrus.FatalLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_tls_socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	ca.issue(t, dir, "server", 2, x509.ExtKeyUsageServerAuth)
+	socket := dir + "/send2slack.sock"
+
+	srv, err := daemon.NewServer(&config.DaemonConfig{
+		ListenUrl: "unix://" + socket,
+		TLS: config.ServerTLS{
+			Cert: dir + "/server.pem",
+			Key:  dir + "/server-key.pem",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dummySender := sender.DummyMessageSender{}
+	srv.MsgSender = &dummySender
+	srv.StartBackground()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := http.Client{
+		Transport: &http.Transport{
+			DialTLSContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				conn, err := d.DialContext(ctx, "unix", socket)
+				if err != nil {
+					return nil, err
+				}
+				return tls.Client(conn, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}), nil
+			},
+		},
+	}
+	res, err := client.Post("https://unix/", "application/json", strings.NewReader(`{"Text":"sample","User":"spoofed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// the user is taken from the socket credentials below the tls connection
+	current, _ := user.Current()
+	got, _ := dummySender.Next(time.Second)
+	if got.User != current.Username {
+		t.Errorf("unexpected user, got \"%s\" expected \"%s\"", got.User, current.Username)
+	}
+}
+
+func TestServerTLSInvalidConfig(t *testing.T) {
+	tcs := []struct {
+		name        string
+		cfg         config.ServerTLS
+		expectedErr string
+	}{
+		{
+			name:        "missing key",
+			cfg:         config.ServerTLS{Cert: "/tmp/cert.pem"},
+			expectedErr: "tls needs both a certificate and a key",
+		},
+		{
+			name:        "client ca without certificate",
+			cfg:         config.ServerTLS{ClientCA: "/tmp/ca.pem"},
+			expectedErr: "tls client_ca requires a server certificate",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := daemon.NewServer(&config.DaemonConfig{ListenUrl: ":4789", TLS: tc.cfg})
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Errorf("unexpected error, got: \"%v\" expected: \"%s\"", err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"send2slack/internal/sender"
+)
+
+// memory used to parse the multipart form, larger files are kept in temporary files
+const maxUploadMemory = 1 << 20
+
+// uploadHandlerFunc receives messages with a file as multipart form, the message is sent as json in
+// the field "message" and the content of the file in the field "file"
+func (srv *Server) uploadHandlerFunc(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "404")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, sender.MaxFileSize+maxUploadMemory)
+	err := r.ParseMultipartForm(maxUploadMemory)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error reading multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var msg sender.Message
+	err = json.Unmarshal([]byte(r.FormValue("message")), &msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error decoding message")
+		return
+	}
+
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: file is missing")
+		return
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "400: error reading file")
+		return
+	}
+
+	if msg.File == nil {
+		msg.File = &sender.File{}
+	}
+	if msg.File.Name == "" {
+		msg.File.Name = header.Filename
+	}
+	msg.File.Content = content
+	setMessageSource(r, &msg)
+
+	srv.deliverMessage(w, &msg)
+}
@@ -0,0 +1,51 @@
+package daemon_test
+
+import (
+	"net/url"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strconv"
+	"testing"
+)
+
+func TestServerUpload(t *testing.T) {
+
+	port, dummySender, stop := startDummyServer(t, &config.DaemonConfig{})
+	defer stop()
+
+	u, err := url.ParseRequestURI("http://127.0.0.1:" + strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode: config.ModeHttpClient,
+		Url:  u,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.SendMessage(&sender.Message{
+		Text:        "nightly report",
+		Destination: "reports",
+		File: &sender.File{
+			Name:     "report.csv",
+			Title:    "report",
+			Filetype: "csv",
+			Content:  []byte("host,errors\nweb-03,2\n"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := dummySender.Last
+	if got.Text != "nightly report" || got.Destination != "reports" || got.File == nil {
+		t.Fatalf("unexpected message: %+v", got)
+	}
+	expected := sender.File{Name: "report.csv", Title: "report", Filetype: "csv", Content: []byte("host,errors\nweb-03,2\n")}
+	if got.File.Name != expected.Name || got.File.Title != expected.Title || got.File.Filetype != expected.Filetype ||
+		string(got.File.Content) != string(expected.Content) {
+		t.Errorf("unexpected file, got: %+v expected: %+v", *got.File, expected)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package doctor
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+)
+
+// checkAccess verifies that the current user can read, and optionally write, the directory
+func checkAccess(dir string, write bool) error {
+	mode := uint32(unix.R_OK | unix.X_OK)
+	perm := "read"
+	if write {
+		mode |= unix.W_OK
+		perm = "read and write"
+	}
+	if err := unix.Access(dir, mode); err != nil {
+		return fmt.Errorf("no permission to %s: %v", perm, err)
+	}
+	return nil
+}
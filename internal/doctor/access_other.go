@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package doctor
+
+// checkAccess is only supported on linux, on other platforms the permissions are not checked
+func checkAccess(dir string, write bool) error {
+	return nil
+}
@@ -0,0 +1,343 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"send2slack/internal/sender"
+	"sort"
+	"strings"
+)
+
+// Check is the result of a single verification, Err is nil if the check passed
+type Check struct {
+	Name string
+	Err  error
+}
+
+// Report collects the checks of a configuration
+type Report struct {
+	Checks []Check
+}
+
+func (r *Report) add(name string, err error) {
+	r.Checks = append(r.Checks, Check{Name: name, Err: err})
+}
+
+// Problems returns the amount of failed checks
+func (r *Report) Problems() int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the checks in a readable form
+func (r *Report) Print(w io.Writer) {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", c.Name, c.Err)
+		} else {
+			fmt.Fprintf(w, "[ ok ] %s\n", c.Name)
+		}
+	}
+	switch p := r.Problems(); p {
+	case 0:
+		fmt.Fprintln(w, "no problems found")
+	case 1:
+		fmt.Fprintln(w, "1 problem found")
+	default:
+		fmt.Fprintf(w, "%d problems found\n", p)
+	}
+}
+
+// Client verifies a client configuration loaded from file, with online the token and the channels are verified
+// with the slack api, or the send2slack server has to be reachable
+func Client(cfg *config.ClientConfig, file string, online bool) *Report {
+
+	r := &Report{}
+	r.checkFile(file, cfg.IsDefault)
+
+	if cfg.Mode == config.ModeHttpClient {
+		r.add("remote url "+cfg.Url.String(), nil)
+		r.checkReadable("tls", cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key)
+	} else {
+		r.checkToken(cfg.Token)
+	}
+
+	if !online {
+		return r
+	}
+
+	sndr, err := sender.NewSlackSender(cfg)
+	if err != nil {
+		r.add("connection", err)
+		return r
+	}
+	if !r.checkConnection(sndr) || cfg.Mode == config.ModeHttpClient {
+		return r
+	}
+
+	channels := []destination{
+		{"default_channel", cfg.DefChannel},
+		{"email_channel", cfg.EmailChannel},
+		{"fallback_channel", cfg.FallbackChannel},
+	}
+	channels = append(channels, severityChannels(cfg.Severities)...)
+	r.checkDestinations(sndr, channels)
+	return r
+}
+
+// Daemon verifies a daemon configuration loaded from file, with online the token and the channels are verified
+// with the slack api, in relay mode the upstream server has to be reachable
+func Daemon(cfg *config.DaemonConfig, file string, online bool) *Report {
+
+	r := &Report{}
+	r.checkFile(file, cfg.IsDefault)
+	for _, f := range cfg.DropIns {
+		r.checkFile(f, false)
+	}
+
+	if cfg.Relay.Url == "" {
+		r.checkToken(cfg.Token)
+	}
+	r.checkListenUrl(cfg.ListenUrl)
+	r.checkReadable("tls", cfg.TLS.Cert, cfg.TLS.Key, cfg.TLS.ClientCA)
+
+	if cfg.WatchDir != "" && cfg.WatchDir != "false" {
+		r.checkDir("mbox_watch", cfg.WatchDir, true)
+	}
+	for _, lw := range cfg.LogWatch {
+		r.checkReadable("logwatch", lw.Path)
+	}
+
+	var relayCfg *config.ClientConfig
+	if cfg.Relay.Url != "" {
+		u, err := config.ParseRemoteUrl(cfg.Relay.Url)
+		r.add("relay url "+cfg.Relay.Url, err)
+		if err != nil {
+			return r
+		}
+		r.checkReadable("relay tls", cfg.Relay.TLS.CA, cfg.Relay.TLS.Cert, cfg.Relay.TLS.Key)
+		if cfg.Relay.Spool != "" {
+			r.checkDir("relay spool", cfg.Relay.Spool, false)
+		}
+		relayCfg = &config.ClientConfig{Mode: config.ModeHttpClient, Url: u, TLS: cfg.Relay.TLS}
+	}
+
+	if !online {
+		return r
+	}
+
+	// in relay mode the channels are resolved by the upstream server
+	if relayCfg != nil {
+		sndr, err := sender.NewSlackSender(relayCfg)
+		if err != nil {
+			r.add("connection", err)
+			return r
+		}
+		r.checkConnection(sndr)
+		return r
+	}
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{Mode: config.ModeDirectCli, Token: cfg.Token})
+	if err != nil {
+		r.add("connection", err)
+		return r
+	}
+	if !r.checkConnection(sndr) {
+		return r
+	}
+	r.checkDestinations(sndr, daemonDestinations(cfg))
+	return r
+}
+
+func (r *Report) checkFile(file string, isDefault bool) {
+	if isDefault || file == "" {
+		r.add("configuration file", errors.New("no configuration file found, the default values are used"))
+		return
+	}
+	r.add("configuration file "+file, nil)
+
+	unknown, err := config.UnknownKeys(file)
+	if err != nil {
+		r.add("configuration keys", err)
+		return
+	}
+	for _, key := range unknown {
+		r.add("key \""+key+"\"", errors.New("unknown key"))
+	}
+
+	// a token in the file is only safe if other users cannot read it
+	plain, err := config.HasPlainToken(file)
+	if err != nil || !plain {
+		return
+	}
+	info, err := os.Stat(file)
+	if err == nil && info.Mode().Perm()&0004 != 0 {
+		r.add("token in "+file, fmt.Errorf("the file is readable by all users, use token_file or restrict the permissions"))
+	}
+}
+
+func (r *Report) checkToken(token string) {
+	if token == "" {
+		r.add("token", errors.New("no token configured in slack.token, slack.token_file, slack.token_command or $SLACK_TOKEN"))
+		return
+	}
+	r.add("token", nil)
+}
+
+// checkListenUrl verifies the listen url of the server, the directory of a unix socket has to be writable
+func (r *Report) checkListenUrl(listenUrl string) {
+
+	name := "listen_url " + listenUrl
+	if listenUrl == "false" {
+		return
+	}
+	if strings.HasPrefix(listenUrl, "unix://") {
+		path := strings.TrimPrefix(listenUrl, "unix://")
+		if path == "" {
+			r.add(name, errors.New("expecting unix://<path>"))
+			return
+		}
+		r.add(name, checkAccess(filepath.Dir(path), true))
+		return
+	}
+	_, _, err := daemon.ParseListenAddress(listenUrl)
+	r.add(name, err)
+}
+
+// checkDir verifies that the directory exists and can be read, and written if the files are modified
+func (r *Report) checkDir(name string, dir string, write bool) {
+	name = name + " " + dir
+	info, err := os.Stat(dir)
+	if err != nil {
+		r.add(name, err)
+		return
+	}
+	if !info.IsDir() {
+		r.add(name, errors.New("not a directory"))
+		return
+	}
+	r.add(name, checkAccess(dir, write))
+}
+
+// checkReadable verifies that the configured files can be read, empty paths are skipped
+func (r *Report) checkReadable(name string, files ...string) {
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		fh, err := os.Open(f)
+		if err == nil {
+			fh.Close()
+		}
+		r.add(name+" "+f, err)
+	}
+}
+
+// checkConnection verifies the token or that the server is reachable, returns false if it failed
+func (r *Report) checkConnection(sndr *sender.SlackSender) bool {
+	res, err := sndr.TestConnection()
+	if err != nil {
+		r.add("connection", err)
+		return false
+	}
+	r.add("connection: "+res, nil)
+	return true
+}
+
+type destination struct {
+	setting string
+	name    string
+}
+
+// checkDestinations verifies that the channels and users exist, every destination is only checked once
+func (r *Report) checkDestinations(sndr *sender.SlackSender, destinations []destination) {
+	checked := map[string]bool{}
+	for _, d := range destinations {
+		if d.name == "" || checked[d.name] {
+			continue
+		}
+		checked[d.name] = true
+		r.add(d.setting+" \""+d.name+"\"", sndr.ValidateDestination(d.name))
+	}
+}
+
+func severityChannels(severities map[string]config.Severity) []destination {
+	var dests []destination
+	for level, sev := range severities {
+		dests = append(dests, destination{"severity " + level, sev.Channel})
+	}
+	sortDestinations(dests)
+	return dests
+}
+
+// daemonDestinations returns all the destinations of the daemon configuration, channels of hooks that are
+// templates are only known when a message is received
+func daemonDestinations(cfg *config.DaemonConfig) []destination {
+
+	dests := []destination{
+		{"default_channel", cfg.DefChannel},
+		{"email_channel", cfg.SendmailChannel},
+		{"fallback_channel", cfg.FallbackChannel},
+	}
+	dests = append(dests, severityChannels(cfg.Severities)...)
+
+	for _, user := range sortedKeys(cfg.MboxUsers) {
+		dests = append(dests, destination{"mbox_users " + user, cfg.MboxUsers[user]})
+	}
+	var hooks []destination
+	for name, hook := range cfg.Hooks {
+		if !strings.Contains(hook.Channel, "{{") {
+			hooks = append(hooks, destination{"hook " + name, hook.Channel})
+		}
+	}
+	sortDestinations(hooks)
+	dests = append(dests, hooks...)
+	for _, git := range []struct {
+		name string
+		cfg  config.GitWebhook
+	}{{"github", cfg.Github}, {"gitlab", cfg.Gitlab}} {
+		dests = append(dests, destination{git.name, git.cfg.Channel})
+		for _, repo := range sortedKeys(git.cfg.Repos) {
+			dests = append(dests, destination{git.name + " " + repo, git.cfg.Repos[repo]})
+		}
+	}
+	for _, f := range cfg.Syslog.Filters {
+		dests = append(dests, destination{"syslog filter", f.Channel})
+	}
+	for _, lw := range cfg.LogWatch {
+		dests = append(dests, destination{"logwatch " + lw.Path, lw.Channel})
+		for _, p := range lw.Patterns {
+			dests = append(dests, destination{"logwatch " + lw.Path, p.Channel})
+		}
+	}
+	for _, rcpt := range sortedKeys(cfg.Smtp.Recipients) {
+		dests = append(dests, destination{"smtp recipient " + rcpt, cfg.Smtp.Recipients[rcpt]})
+	}
+	return dests
+}
+
+// sortedKeys returns the keys of the map in order, the checks are reported in the same order on every run
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortDestinations(dests []destination) {
+	sort.Slice(dests, func(i, j int) bool {
+		return dests[i].setting < dests[j].setting
+	})
+}
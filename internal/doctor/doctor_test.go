@@ -0,0 +1,178 @@
+package doctor_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/doctor"
+	"strings"
+	"testing"
+)
+
+// failed returns the names of the failed checks
+func failed(r *doctor.Report) []string {
+	var names []string
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// writeConfig writes a configuration file containing a token
+func writeConfig(t *testing.T, file string, mode os.FileMode) string {
+	err := ioutil.WriteFile(file, []byte("slack:\n  token: \"xoxb-1\"\n"), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the umask can restrict the mode of WriteFile
+	err = os.Chmod(file, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestDaemon(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := dir + "/server.yaml"
+	err = ioutil.WriteFile(file, []byte("slack:\n  sendmail_channel: general\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := writeConfig(t, dir+"/valid.yaml", 0600)
+	readable := writeConfig(t, dir+"/readable.yaml", 0644)
+
+	tcs := []struct {
+		name     string
+		cfg      config.DaemonConfig
+		file     string
+		expected []string
+	}{
+		{
+			name:     "valid",
+			cfg:      config.DaemonConfig{Token: "xoxb-1", ListenUrl: "127.0.0.1:4789", WatchDir: dir},
+			expected: nil,
+		},
+		{
+			name: "invalid values",
+			cfg:  config.DaemonConfig{ListenUrl: "localhost:http", WatchDir: dir + "/missing"},
+			file: file,
+			expected: []string{
+				"key \"slack.sendmail_channel\"",
+				"token",
+				"listen_url localhost:http",
+				"mbox_watch " + dir + "/missing",
+			},
+		},
+		{
+			name:     "token in a world readable file",
+			cfg:      config.DaemonConfig{Token: "xoxb-1", ListenUrl: "false", WatchDir: "false"},
+			file:     readable,
+			expected: []string{"token in " + readable},
+		},
+		{
+			name:     "relay without token",
+			cfg:      config.DaemonConfig{ListenUrl: "false", WatchDir: "false", Relay: config.Relay{Url: "relay.example.com:4789"}},
+			expected: nil,
+		},
+		{
+			name:     "default configuration",
+			cfg:      config.DaemonConfig{IsDefault: true, Token: "xoxb-1", ListenUrl: "unix://" + dir + "/s2s.sock", WatchDir: "false"},
+			expected: []string{"configuration file"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.file
+			if f == "" && !tc.cfg.IsDefault {
+				f = valid
+			}
+			r := doctor.Daemon(&tc.cfg, f, false)
+			got := failed(r)
+			if strings.Join(got, "|") != strings.Join(tc.expected, "|") {
+				t.Errorf("unexpected failed checks, got %q expected %q", got, tc.expected)
+			}
+			if (r.Problems() > 0) != (len(tc.expected) > 0) {
+				t.Errorf("unexpected amount of problems: %d", r.Problems())
+			}
+		})
+	}
+}
+
+func TestClient_Online(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir+"/client.yaml", 0600)
+
+	u, _ := url.Parse(srv.URL)
+	r := doctor.Client(&config.ClientConfig{Mode: config.ModeHttpClient, Url: u}, file, true)
+	if r.Problems() != 0 {
+		t.Errorf("unexpected problems: %q", failed(r))
+	}
+
+	srv.Close()
+	r = doctor.Client(&config.ClientConfig{Mode: config.ModeHttpClient, Url: u}, file, true)
+	if got := failed(r); len(got) != 1 || got[0] != "connection" {
+		t.Errorf("expected the connection to fail, got %q", got)
+	}
+
+	var out bytes.Buffer
+	r.Print(&out)
+	if !strings.HasSuffix(out.String(), "1 problem found\n") {
+		t.Errorf("unexpected report: %s", out.String())
+	}
+}
+
+func TestClient_OnlineUnverifiedChannel(t *testing.T) {
+
+	// the token is valid but cannot list the channels
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth.test":
+			w.Write([]byte(`{"ok":true,"user":"bot","team":"team"}`))
+		default:
+			w.Write([]byte(`{"ok":false,"error":"missing_scope"}`))
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir+"/client.yaml", 0600)
+
+	r := doctor.Client(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		Token:      "xoxb-1",
+		ApiUrl:     srv.URL + "/",
+		DefChannel: "general",
+	}, file, true)
+	if got := failed(r); len(got) != 1 || got[0] != "default_channel \"general\"" {
+		t.Errorf("expected the unverified channel to be reported, got %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maximum size of a file sent to slack
+const MaxFileSize = 50 << 20
+
+// UploadPath is the endpoint of the send2slack server receiving messages with a file
+const UploadPath = "/upload"
+
+// File is uploaded together with a message, the text of the message is used as comment
+type File struct {
+	Name     string // file name shown in slack
+	Title    string
+	Filetype string // syntax type of snippets, i.e. "diff" or "go", detected by slack if empty
+	Snippet  bool   // upload the content as text snippet instead of a file
+	Content  []byte
+}
+
+// ReadFile reads a file to be sent, the size is limited to MaxFileSize
+func ReadFile(path string) (*File, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(f, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxFileSize {
+		return nil, fmt.Errorf("file %s exceeds the maximum size of %d MB", path, MaxFileSize>>20)
+	}
+
+	file := File{
+		Name:    filepath.Base(path),
+		Content: content,
+	}
+	return &file, nil
+}
+
+// uploadFile uploads the file of the message to the destination, the text of the message is used as comment
+func (c *SlackSender) uploadFile(msg *slackMessage, destination string, threadTs string) error {
+
+	file := msg.File
+	title := file.Title
+	if title == "" {
+		title = file.Name
+	}
+
+	params := slack.UploadFileV2Parameters{
+		Reader:          bytes.NewReader(file.Content),
+		FileSize:        len(file.Content),
+		Filename:        file.Name,
+		Title:           title,
+		InitialComment:  msg.Text,
+		Channel:         destination,
+		ThreadTimestamp: threadTs,
+	}
+	// slack only creates a snippet if the type is set
+	if file.Snippet {
+		params.SnippetType = file.Filetype
+		if params.SnippetType == "" {
+			params.SnippetType = "text"
+		}
+	}
+
+	_, err := c.client.UploadFileV2(params)
+	if err != nil {
+		return fmt.Errorf("error uploading file: %w\n", err)
+	}
+	log.Debugf("file %s uploaded", file.Name)
+	return nil
+}
+
+// newUploadRequest creates the multipart request sending a message with its file to a send2slack server,
+// the message is sent as json in the field "message" and the content of the file in the field "file"
+func newUploadRequest(requestUrl string, msg *Message) (*http.Request, error) {
+
+	m := *msg
+	file := *msg.File
+	file.Content = nil
+	m.File = &file
+
+	jsonMsg, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	err = w.WriteField("message", string(jsonMsg))
+	if err != nil {
+		return nil, err
+	}
+	part, err := w.CreateFormFile("file", file.Name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(msg.File.Content)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(requestUrl, "/")+UploadPath, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req, nil
+}
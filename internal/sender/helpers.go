@@ -1,59 +1,36 @@
 package sender
 
 import (
-	"bufio"
-	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"strings"
-	"time"
 )
 
-// reads up to max byte size from stdin and returns the content as string
-// exits if it did not get any character after some time, this is used to
-// determine if it has been invoked as part of a script or manually
-func InReader(max int) (string, error) {
-	r := bufio.NewReader(os.Stdin)
-	s := strings.Builder{}
-	initialSignal := false
-	ch := make(chan bool, 1)
-	chFin := make(chan int, 1)
-	byteCount := 0
+// default maximum size of a message read from stdin
+const DefaultMaxInputSize = 1 << 20
 
-	go func() {
-
-		b := make([]byte, 8)
-		for {
-			n, err := r.Read(b)
-			byteCount = byteCount + n
-			if byteCount >= max {
-				chFin <- 1
-			}
-			if initialSignal == false {
-				ch <- true
-				initialSignal = true
-			}
-
-			s.Write(b[:n])
-			if err == io.EOF {
-				chFin <- 0
-				break
-			}
-		}
-
-	}()
+// IsPiped reports whether the file is a pipe or a regular file instead of a terminal, this is used to
+// determine if send2slack has been invoked as part of a script or manually
+func IsPiped(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
 
-	select {
-	case <-ch:
+// InReader reads the input until EOF and returns the content as string, the input is rejected if it
+// exceeds max bytes
+func InReader(r io.Reader, max int64) (string, error) {
 
-	case <-time.After(50000000 * time.Nanosecond):
-		return "", errors.New("io reader not started")
+	// one more byte than allowed is read to detect inputs that are too large
+	content, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return "", err
 	}
-
-	fin := <-chFin
-	if fin == 1 {
-		return "", errors.New("io reader size exceeded")
+	if int64(len(content)) > max {
+		return "", fmt.Errorf("input exceeds the maximum size of %d bytes", max)
 	}
-	return s.String(), nil
-
+	return string(content), nil
 }
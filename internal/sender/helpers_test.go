@@ -0,0 +1,85 @@
+package sender_test
+
+import (
+	"io/ioutil"
+	"os"
+	"send2slack/internal/sender"
+	"strings"
+	"testing"
+)
+
+func TestInReader(t *testing.T) {
+	tcs := []struct {
+		name        string
+		in          string
+		max         int64
+		expectedErr string
+	}{
+		{
+			name: "within limit",
+			in:   "line1\nline2\n",
+			max:  12,
+		},
+		{
+			name:        "exceeds limit",
+			in:          "line1\nline2\n",
+			max:         11,
+			expectedErr: "input exceeds the maximum size of 11 bytes",
+		},
+		{
+			name: "empty input",
+			in:   "",
+			max:  10,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sender.InReader(strings.NewReader(tc.in), tc.max)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("unexpected error, got: \"%v\" expected: \"%s\"", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.in {
+				t.Errorf("unexpected content, got \"%s\" expected \"%s\"", got, tc.in)
+			}
+		})
+	}
+}
+
+func TestIsPiped(t *testing.T) {
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if !sender.IsPiped(r) {
+		t.Error("expected a pipe to be detected")
+	}
+
+	f, err := ioutil.TempFile("/tmp", "s2s_stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if !sender.IsPiped(f) {
+		t.Error("expected a redirected file to be detected")
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if sender.IsPiped(null) {
+		t.Error("expected a character device not to be read")
+	}
+}
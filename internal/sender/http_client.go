@@ -0,0 +1,79 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"send2slack/internal/config"
+	"time"
+)
+
+// timeout of a request to a send2slack server, including the upload of files
+const httpClientTimeout = 2 * time.Minute
+
+// newHttpClient returns the http client and the request url to reach a send2slack server,
+// for unix:// urls the requests are sent over the unix socket
+func newHttpClient(u *url.URL, cfg config.ClientTLS) (*http.Client, string, error) {
+
+	tlsCfg, err := newClientTLSConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// keep the proxy settings, timeouts and connection limits of the default transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   httpClientTimeout,
+	}
+	if u.Scheme != "unix" {
+		return client, u.String(), nil
+	}
+
+	socket := u.Path
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return client, "http://unix/", nil
+}
+
+// newClientTLSConfig loads the CA bundle and the client certificate, returns nil if none is configured
+func newClientTLSConfig(cfg config.ClientTLS) (*tls.Config, error) {
+
+	if cfg.CA == "" && cfg.Cert == "" && cfg.Key == "" {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CA != "" {
+		pem, err := ioutil.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca \"%s\"", cfg.CA)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.Cert != "" || cfg.Key != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
@@ -7,14 +7,26 @@ import (
 	"time"
 )
 
+// origins of a message, the origin selects how the message is represented
+const (
+	OriginEmail = "email"
+)
+
 type Message struct {
-	origin      string
+	Origin      string // i.e. "email", empty for plain messages
+	Host        string // hostname of the machine the message was sent from
+	User        string // local user that sent the message
 	Destination string
 	Text        string
 	Color       string
+	Severity    string // one of info, warning, error or critical
+	Resolved    bool   // the message resolves an alert, the severity only selects the channel, no emoji or mention is added
+	ThreadKey   string // messages with the same thread key are posted as replies to the first one
+	Update      bool   // replace the first message of the thread key instead of replying to it
 	Debug       bool
 	Meta        map[string]string
 	Date        time.Time
+	File        *File `json:",omitempty"` // optional file or snippet, the text is used as comment
 }
 
 type Email struct {
@@ -41,14 +53,32 @@ func (m *Message) getColor() string {
 }
 
 const (
-	EmptyBodyError = "text cannot be empty"
+	EmptyBodyError       = "text cannot be empty"
+	InvalidSeverityError = "severity must be one of: info, warning, error, critical"
+	EmptyFileError       = "file cannot be empty"
+)
+
+// errors returned by Validate
+var (
+	ErrEmptyBody       = errors.New(EmptyBodyError)
+	ErrInvalidSeverity = errors.New(InvalidSeverityError)
+	ErrEmptyFile       = errors.New(EmptyFileError)
 )
 
 // validates if the message fulfils the minimal requirement to be sent
 func (m *Message) Validate() error {
 
-	if m.Text == "" {
-		return errors.New(EmptyBodyError)
+	// messages with a file don't need a text
+	if m.Text == "" && m.File == nil {
+		return ErrEmptyBody
+	}
+
+	if !IsValidSeverity(m.Severity) {
+		return ErrInvalidSeverity
+	}
+
+	if m.File != nil && len(m.File.Content) == 0 {
+		return ErrEmptyFile
 	}
 
 	return nil
@@ -101,7 +131,7 @@ func NewMessageFromMail(m Email) (*Message, error) {
 	msg := Message{
 		Meta:   m.Headers,
 		Text:   m.Body,
-		origin: "email",
+		Origin: OriginEmail,
 	}
 
 	// check for a header "channel"
@@ -116,6 +146,9 @@ func NewMessageFromMail(m Email) (*Message, error) {
 		msg.Color = c
 	}
 
+	// check the priority headers
+	msg.Severity = severityFromMailHeaders(m.Headers)
+
 	return &msg, nil
 }
 
@@ -58,6 +58,35 @@ signature
 	}
 }
 
+func TestMessageFromMailStr_Severity(t *testing.T) {
+	tcs := []struct {
+		header   string
+		expected string
+	}{
+		{header: "X-Priority: 1 (Highest)", expected: sender.SeverityCritical},
+		{header: "X-Priority: 2", expected: sender.SeverityError},
+		{header: "X-Priority: 3 (Normal)", expected: ""},
+		{header: "X-Priority: 5 (Lowest)", expected: sender.SeverityInfo},
+		{header: "Importance: High", expected: sender.SeverityError},
+		{header: "X-Slack-Severity: warning", expected: sender.SeverityWarning},
+		{header: "X-Slack-Severity: warn", expected: ""},
+		{header: "X-Slack-Severity: high\nX-Priority: 1", expected: sender.SeverityCritical},
+		{header: "Subject: no priority", expected: ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.header, func(t *testing.T) {
+			out, err := sender.NewMessageFromMailStr(tc.header + "\n\nbody\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Severity != tc.expected {
+				t.Errorf("unexpected severity, got: \"%s\" expected: \"%s\"", out.Severity, tc.expected)
+			}
+		})
+	}
+}
+
 type valdationScenatio struct {
 	name        string
 	msg         sender.Message
@@ -72,6 +101,15 @@ func TestMessage_Validate(t *testing.T) {
 			msg:         sender.Message{},
 			expectError: sender.EmptyBodyError,
 		},
+		{
+			name:        "invalid severity",
+			msg:         sender.Message{Text: "text", Severity: "urgent"},
+			expectError: sender.InvalidSeverityError,
+		},
+		{
+			name: "valid severity",
+			msg:  sender.Message{Text: "text", Severity: sender.SeverityWarning},
+		},
 	}
 
 	for _, tc := range tcs {
@@ -97,3 +135,13 @@ func TestMessage_Validate(t *testing.T) {
 	}
 
 }
+
+func TestMessageFromMailOrigin(t *testing.T) {
+	out, err := sender.NewMessageFromMailStr("From: root@web-03\nSubject: cron\n\nbody\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Origin != sender.OriginEmail {
+		t.Errorf("unexpected origin, got: \"%s\" expected: \"%s\"", out.Origin, sender.OriginEmail)
+	}
+}
@@ -0,0 +1,36 @@
+package sender
+
+import (
+	"strings"
+)
+
+// Preview returns the destination and the text of a message as it would be posted, without sending it,
+// the text of a colored attachment is shown below the message text prefixed with a bar
+func (c *SlackSender) Preview(msg *Message) (string, string, error) {
+
+	err := msg.Validate()
+	if err != nil {
+		return "", "", err
+	}
+
+	m := *msg
+	slkMsg, err := c.transformMsg(&m)
+	if err != nil {
+		return "", "", err
+	}
+
+	var sb strings.Builder
+	if slkMsg.Text != "" {
+		sb.WriteString(slkMsg.Text + "\n")
+	}
+	if slkMsg.att != nil && slkMsg.att.Text != "" {
+		sb.WriteString("[color " + slkMsg.att.Color + "]\n")
+		for _, line := range strings.Split(slkMsg.att.Text, "\n") {
+			sb.WriteString("┃ " + line + "\n")
+		}
+	}
+	if slkMsg.File != nil {
+		sb.WriteString("[file " + slkMsg.File.Name + "]\n")
+	}
+	return slkMsg.Destination, sb.String(), nil
+}
@@ -0,0 +1,107 @@
+package sender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"send2slack/internal/config"
+)
+
+// RelaySender forwards the messages to an upstream send2slack server, if the server is not reachable
+// the messages are kept in the spool and sent again later
+type RelaySender struct {
+	client             *SlackSender
+	spool              *spool // nil if no spool directory is configured
+	defaultDestination string
+}
+
+// NewRelaySender sends the messages to the server in cfg.Url, spoolDir is optional
+func NewRelaySender(cfg *config.ClientConfig, spoolDir string) (*RelaySender, error) {
+
+	clientCfg := *cfg
+	clientCfg.Mode = config.ModeHttpClient
+	client, err := NewSlackSender(&clientCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := RelaySender{
+		client:             client,
+		defaultDestination: cfg.DefChannel,
+	}
+	if spoolDir != "" {
+		rs.spool, err = openSpool(spoolDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &rs, nil
+}
+
+// SendMessage forwards the message, if the upstream server is not reachable and a spool is configured
+// the message is spooled and no error is returned. while older messages are waiting in the spool the message
+// is added to the spool without contacting the upstream server, the spool is sent by Flush
+func (rs *RelaySender) SendMessage(msg *Message) error {
+
+	err := msg.Validate()
+	if err != nil {
+		return err
+	}
+
+	m := *msg
+	if m.Destination == "" {
+		m.Destination = rs.defaultDestination
+	}
+
+	if rs.spool == nil {
+		return rs.client.SendMessage(&m)
+	}
+
+	// keep the order, new messages are only sent directly if no older message is waiting
+	spooled, err := rs.spool.pushIfWaiting(&m)
+	if err != nil {
+		return fmt.Errorf("unable to spool message: %v", err)
+	}
+	if spooled {
+		return nil
+	}
+
+	err = rs.client.SendMessage(&m)
+	if err == nil || isPermanentError(err) {
+		return err
+	}
+
+	spoolErr := rs.spool.push(&m)
+	if spoolErr != nil {
+		return fmt.Errorf("unable to spool message: %v, upstream error: %v", spoolErr, err)
+	}
+	log.Warnf("upstream not reachable, message spooled: %v", err)
+	return nil
+}
+
+// Flush sends the spooled messages, returns the amount of messages still waiting
+func (rs *RelaySender) Flush() (int, error) {
+	if rs.spool == nil {
+		return 0, nil
+	}
+	return rs.spool.flush(rs.client.SendMessage)
+}
+
+// SendError send an error to the default destination
+func (rs *RelaySender) SendError(err error) {
+	msg := Message{
+		Text:  err.Error(),
+		Color: "red",
+	}
+	_ = rs.SendMessage(&msg)
+}
+
+// isPermanentError returns true for errors that will not change by retrying, i.e. the server rejecting the message
+func isPermanentError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return true
+	}
+	return IsRejected(err) || errors.Is(err, ErrEmptyBody) || errors.Is(err, ErrInvalidSeverity) || errors.Is(err, ErrEmptyFile)
+}
@@ -0,0 +1,218 @@
+package sender_test
+
+import (
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"sync"
+	"testing"
+)
+
+// upstream is a send2slack server that can be switched off
+type upstream struct {
+	mutex    sync.Mutex
+	status   int
+	failing  map[string]int // status of the messages to a destination, i.e. a channel that does not exist
+	received []string
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var msg sender.Message
+	json.NewDecoder(r.Body).Decode(&msg)
+	if status, ok := u.failing[msg.Destination]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	if u.status == http.StatusAccepted {
+		u.received = append(u.received, msg.Destination+":"+msg.Text)
+	}
+	w.WriteHeader(u.status)
+}
+
+func (u *upstream) setStatus(status int) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.status = status
+}
+
+func TestRelaySender(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	up := &upstream{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(up)
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	relay, err := sender.NewRelaySender(&config.ClientConfig{Url: u, DefChannel: "edge"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spooled := func() int {
+		files, _ := filepath.Glob(dir + "/*.json")
+		return len(files)
+	}
+
+	t.Run("spool while upstream is down", func(t *testing.T) {
+		for _, text := range []string{"first", "second"} {
+			err := relay.SendMessage(&sender.Message{Text: text})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		if spooled() != 2 {
+			t.Errorf("expected 2 spooled messages, got %d", spooled())
+		}
+	})
+
+	t.Run("send spooled messages in order", func(t *testing.T) {
+		up.setStatus(http.StatusAccepted)
+		err := relay.SendMessage(&sender.Message{Text: "third", Destination: "ops"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(up.received) != 0 || spooled() != 3 {
+			t.Errorf("expected the message to be spooled behind the waiting messages, got %v", up.received)
+		}
+
+		pending, err := relay.Flush()
+		if pending != 0 || err != nil {
+			t.Errorf("expected all messages to be sent, got %d, %v", pending, err)
+		}
+		expected := []string{"edge:first", "edge:second", "ops:third"}
+		if len(up.received) != len(expected) {
+			t.Fatalf("unexpected messages, got %v expected %v", up.received, expected)
+		}
+		for i := range expected {
+			if up.received[i] != expected[i] {
+				t.Errorf("unexpected messages, got %v expected %v", up.received, expected)
+			}
+		}
+		if spooled() != 0 {
+			t.Errorf("expected empty spool, got %d messages", spooled())
+		}
+	})
+
+	t.Run("rejected messages are not spooled", func(t *testing.T) {
+		up.setStatus(http.StatusBadRequest)
+		err := relay.SendMessage(&sender.Message{Text: "rejected"})
+		if err == nil || err.Error() != "message not submitted" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if spooled() != 0 {
+			t.Errorf("expected empty spool, got %d messages", spooled())
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		up.setStatus(http.StatusBadGateway)
+		relay.SendMessage(&sender.Message{Text: "later"})
+
+		pending, err := relay.Flush()
+		if pending != 1 || err == nil {
+			t.Errorf("expected 1 pending message and an error, got %d, %v", pending, err)
+		}
+
+		up.setStatus(http.StatusAccepted)
+		pending, err = relay.Flush()
+		if pending != 0 || err != nil {
+			t.Errorf("expected all messages to be sent, got %d, %v", pending, err)
+		}
+	})
+}
+
+func TestRelaySender_Undeliverable(t *testing.T) {
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	up := &upstream{
+		status: http.StatusAccepted,
+		failing: map[string]int{
+			// servers before the rejection status answered slack errors with 500
+			"missing": http.StatusInternalServerError,
+			"gone":    http.StatusUnprocessableEntity,
+			// the token of the upstream server was revoked
+			"revoked": http.StatusServiceUnavailable,
+		},
+	}
+	ts := httptest.NewServer(up)
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	relay, err := sender.NewRelaySender(&config.ClientConfig{Url: u, DefChannel: "edge"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("rejected by slack", func(t *testing.T) {
+		err := relay.SendMessage(&sender.Message{Text: "bad channel", Destination: "gone"})
+		if !sender.IsRejected(err) {
+			t.Errorf("expected the message to be rejected, got: %v", err)
+		}
+		err = relay.SendMessage(&sender.Message{Text: "next"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(up.received) != 1 || up.received[0] != "edge:next" {
+			t.Errorf("expected the next message to be sent directly, got %v", up.received)
+		}
+	})
+
+	t.Run("500 for a bad channel does not block the spool", func(t *testing.T) {
+		up.received = nil
+		for _, msg := range []sender.Message{{Text: "bad channel", Destination: "missing"}, {Text: "after"}} {
+			err := relay.SendMessage(&msg)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+
+		flushes := 0
+		for pending := 1; pending > 0 && flushes < 20; flushes++ {
+			pending, _ = relay.Flush()
+		}
+		if flushes >= 20 {
+			t.Fatalf("the undeliverable message was never discarded")
+		}
+		if len(up.received) != 1 || up.received[0] != "edge:after" {
+			t.Errorf("expected the later message to be delivered, got %v", up.received)
+		}
+	})
+
+	t.Run("503 keeps the message", func(t *testing.T) {
+		err := relay.SendMessage(&sender.Message{Text: "token revoked", Destination: "revoked"})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			relay.Flush()
+		}
+		files, _ := filepath.Glob(dir + "/*.json")
+		if len(files) != 1 {
+			t.Errorf("expected the message to stay in the spool, got %d messages", len(files))
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	userEmailPrefix  = "user:"
+	userHandlePrefix = "@"
+	channelPrefix    = "#"
+)
+
+const (
+	// time after which the channel list is fetched again from slack
+	channelCacheTtl = 10 * time.Minute
+	// minimum time between channel list refreshes caused by an unknown channel
+	channelRefreshInterval = 1 * time.Minute
+)
+
+// slack conversation and user ids, i.e. C024BE91L, G024BE91L, D024BE91L, U024BE7LH or W024BE7LH,
+// messages to user ids are delivered by slack to the direct message with the app. channel names are always lowercase
+var conversationIdRegex = regexp.MustCompile(`^[CGDUW][A-Z0-9]{6,}$`)
+
+// ErrChannelUnverified is returned when validating a channel name while the channel list cannot be loaded,
+// messages to the channel are still sent with the name and slack resolves it
+var ErrChannelUnverified = errors.New("channel list not available, the channel is not verified")
+
+// destinationResolver translates destinations into slack conversation ids:
+// users, like "@alice" or "user:alice@example.com", are resolved to the direct message conversation with that user
+// and channel names, like "#general" or "general" are resolved to the channel id.
+// resolved destinations are cached, so that the slack api is only queried when needed. mutex protects the caches
+// and is never held during api calls, loadMutex makes sure only one channel list request runs at a time
+type destinationResolver struct {
+	client    *slack.Client
+	mutex     sync.Mutex
+	loadMutex sync.Mutex
+	cache     map[string]string
+
+	channels       map[string]string // channel name to id
+	channelsLoaded time.Time
+	channelsFailed time.Time // last failed attempt to load the channel list
+}
+
+func newDestinationResolver(client *slack.Client) *destinationResolver {
+	return &destinationResolver{
+		client:   client,
+		cache:    map[string]string{},
+		channels: map[string]string{},
+	}
+}
+
+// isUserDestination returns true if the destination addresses a user instead of a channel
+func isUserDestination(dest string) bool {
+	return strings.HasPrefix(dest, userEmailPrefix) || strings.HasPrefix(dest, userHandlePrefix)
+}
+
+// resolve returns the conversation id to be used for the passed destination, channel names that cannot be
+// verified are returned unchanged
+func (r *destinationResolver) resolve(dest string) (string, error) {
+	id, err := r.validate(dest)
+	if errors.Is(err, ErrChannelUnverified) {
+		return dest, nil
+	}
+	return id, err
+}
+
+// validate returns the conversation id of the destination, channel names are reported with
+// ErrChannelUnverified if the channel list cannot be loaded
+func (r *destinationResolver) validate(dest string) (string, error) {
+
+	if conversationIdRegex.MatchString(dest) {
+		return dest, nil
+	}
+
+	if !isUserDestination(dest) {
+		return r.resolveChannel(dest)
+	}
+
+	r.mutex.Lock()
+	id, ok := r.cache[dest]
+	r.mutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	userId, err := r.lookupUser(dest)
+	if err != nil {
+		return "", err
+	}
+
+	channel, _, _, err := r.client.OpenConversation(&slack.OpenConversationParameters{
+		Users: []string{userId},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to open direct message with \"%s\": %w", dest, err)
+	}
+
+	r.mutex.Lock()
+	r.cache[dest] = channel.ID
+	r.mutex.Unlock()
+	return channel.ID, nil
+}
+
+// lookupUser returns the slack user id for a user destination
+func (r *destinationResolver) lookupUser(dest string) (string, error) {
+
+	if strings.HasPrefix(dest, userEmailPrefix) {
+		email := strings.TrimPrefix(dest, userEmailPrefix)
+		user, err := r.client.GetUserByEmail(email)
+		if err != nil {
+			return "", fmt.Errorf("unable to find slack user with email \"%s\": %w", email, err)
+		}
+		return user.ID, nil
+	}
+
+	handle := strings.TrimPrefix(dest, userHandlePrefix)
+	users, err := r.client.GetUsers()
+	if err != nil {
+		return "", fmt.Errorf("unable to list slack users: %w", err)
+	}
+	for _, user := range users {
+		if user.Deleted {
+			continue
+		}
+		if user.Name == handle || user.Profile.DisplayName == handle {
+			return user.ID, nil
+		}
+	}
+	return "", fmt.Errorf("unable to find slack user with handle \"%s\": %w", handle, slack.SlackErrorResponse{Err: "users_not_found"})
+}
+
+// resolveChannel returns the id of a channel name using the cached channel list,
+// the list is refreshed once expired or if the channel is not found.
+// if the channel list cannot be loaded, i.e. the token is missing the channels:read scope, ErrChannelUnverified
+// is returned and the name is sent to slack which resolves it when posting.
+func (r *destinationResolver) resolveChannel(dest string) (string, error) {
+
+	name := strings.TrimPrefix(dest, channelPrefix)
+
+	id, found, stale := r.cachedChannel(name)
+	if stale {
+		r.refreshChannels()
+		id, found, _ = r.cachedChannel(name)
+		if !found && r.channelListFailed() {
+			return dest, ErrChannelUnverified
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("%w: \"#%s\"", slack.SlackErrorResponse{Err: "channel_not_found"}, name)
+	}
+	return id, nil
+}
+
+// cachedChannel returns the id of a channel from the cached list, stale is true if the list is expired or
+// the channel is not found and the list is older than the refresh interval
+func (r *destinationResolver) cachedChannel(name string) (id string, found bool, stale bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	age := time.Since(r.channelsLoaded)
+	id, found = r.channels[name]
+	return id, found, age > channelCacheTtl || (!found && age > channelRefreshInterval)
+}
+
+// channelListFailed returns true if the last attempt to load the channel list failed
+func (r *destinationResolver) channelListFailed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.channelsFailed.After(r.channelsLoaded)
+}
+
+// refreshChannels loads the channel list, unless it was loaded or failed to load during the refresh interval,
+// i.e. by a concurrent request. the cache is only locked to swap in the new list
+func (r *destinationResolver) refreshChannels() {
+
+	r.loadMutex.Lock()
+	defer r.loadMutex.Unlock()
+
+	// after a failed attempt the list is not requested again before the refresh interval
+	r.mutex.Lock()
+	recent := time.Since(r.channelsLoaded) <= channelRefreshInterval || time.Since(r.channelsFailed) <= channelRefreshInterval
+	r.mutex.Unlock()
+	if recent {
+		return
+	}
+
+	channels, err := r.loadChannels()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		r.channelsFailed = time.Now()
+		log.Warnf("unable to load the slack channel list, channel names are sent unresolved: %v", err)
+		return
+	}
+	r.channels = channels
+	r.channelsLoaded = time.Now()
+}
+
+// loadChannels fetches all channels visible to the token
+func (r *destinationResolver) loadChannels() (map[string]string, error) {
+
+	channels := map[string]string{}
+	cursor := ""
+	for {
+		list, next, err := r.client.GetConversations(&slack.GetConversationsParameters{
+			Cursor:          cursor,
+			ExcludeArchived: true,
+			Limit:           1000,
+			Types:           []string{"public_channel", "private_channel"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range list {
+			channels[ch.Name] = ch.ID
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	return channels, nil
+}
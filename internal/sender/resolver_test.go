@@ -0,0 +1,318 @@
+package sender_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"send2slack/internal/config"
+	"send2slack/internal/sender"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSlack is a minimal slack web api used to verify which api calls the sender performs
+type fakeSlack struct {
+	server   *httptest.Server
+	mutex    sync.Mutex
+	calls    map[string]int
+	channels []string // channels passed to chat.postMessage
+	texts    []string // texts passed to chat.postMessage
+	threads  []string // thread timestamps passed to chat.postMessage
+	uploads  []string // content and thread timestamp of the files shared with files.completeUploadExternal
+	snippets []string // snippet types passed to files.getUploadURLExternal
+	files    map[string]string
+	hold     map[string]chan struct{} // requests of a method wait until the channel is closed
+}
+
+func newFakeSlack(responses map[string]string) *fakeSlack {
+	fs := fakeSlack{
+		calls: map[string]int{},
+		files: map[string]string{},
+	}
+	fs.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// the content of external uploads is posted to the upload url returned by files.getUploadURLExternal
+		if strings.HasPrefix(r.URL.Path, "/upload/") {
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := ioutil.ReadAll(f)
+			fs.mutex.Lock()
+			fs.files[strings.TrimPrefix(r.URL.Path, "/upload/")] = string(content)
+			fs.mutex.Unlock()
+			return
+		}
+
+		method := r.URL.Path[len("/api/"):]
+		r.ParseForm()
+		if hold, ok := fs.hold[method]; ok {
+			<-hold
+		}
+
+		fs.mutex.Lock()
+		fs.calls[method]++
+		if method == "chat.postMessage" || method == "chat.update" {
+			fs.channels = append(fs.channels, r.FormValue("channel"))
+			fs.texts = append(fs.texts, r.FormValue("text"))
+			fs.threads = append(fs.threads, r.FormValue("thread_ts"))
+		}
+		var fileId string
+		if method == "files.getUploadURLExternal" {
+			fileId = fmt.Sprintf("F%02d", fs.calls[method])
+			fs.snippets = append(fs.snippets, r.FormValue("snippet_type"))
+		}
+		if method == "files.completeUploadExternal" {
+			var files []struct{ ID string }
+			json.Unmarshal([]byte(r.FormValue("files")), &files)
+			for _, f := range files {
+				fs.uploads = append(fs.uploads, fs.files[f.ID]+"|"+r.FormValue("thread_ts"))
+			}
+		}
+		fs.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp, ok := responses[method]; ok {
+			fmt.Fprint(w, resp)
+			return
+		}
+		switch method {
+		case "files.getUploadURLExternal":
+			fmt.Fprint(w, `{"ok":true,"upload_url":"`+fs.server.URL+`/upload/`+fileId+`","file_id":"`+fileId+`"}`)
+			return
+		case "files.completeUploadExternal":
+			fmt.Fprint(w, `{"ok":true,"files":[{"id":"F01"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"channel":"`+r.FormValue("channel")+`","ts":"1.1"}`)
+	}))
+	return &fs
+}
+
+func (fs *fakeSlack) url() string {
+	return fs.server.URL + "/api/"
+}
+
+func (fs *fakeSlack) close() {
+	fs.server.Close()
+}
+
+func TestSlackSender_UserDestinations(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"users.lookupByEmail": `{"ok":true,"user":{"id":"U01","name":"alice"}}`,
+		"users.list":          `{"ok":true,"members":[{"id":"U02","name":"bob"},{"id":"U03","name":"old","deleted":true}]}`,
+		"conversations.open":  `{"ok":true,"channel":{"id":"D-DM"}}`,
+		"conversations.list":  `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"}]}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:   config.ModeDirectCli,
+		ApiUrl: fs.url(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("user by email", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			err = sndr.SendMessage(&sender.Message{Destination: "user:alice@example.com", Text: "hi"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the second message has to use the cached conversation
+		if fs.calls["users.lookupByEmail"] != 1 {
+			t.Errorf("expected 1 email lookup, got %d", fs.calls["users.lookupByEmail"])
+		}
+		if fs.channels[0] != "D-DM" {
+			t.Errorf("unexpected destination, got \"%s\" expected \"D-DM\"", fs.channels[0])
+		}
+	})
+
+	t.Run("user by handle", func(t *testing.T) {
+		err = sndr.SendMessage(&sender.Message{Destination: "@bob", Text: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fs.calls["users.list"] != 1 {
+			t.Errorf("expected 1 user list call, got %d", fs.calls["users.list"])
+		}
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		err = sndr.SendMessage(&sender.Message{Destination: "@old", Text: "hi"})
+		expected := "unable to find slack user with handle \"old\""
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing \"%s\", got %v", expected, err)
+		}
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		for _, dest := range []string{"general", "#general", "C0GENERAL"} {
+			err = sndr.SendMessage(&sender.Message{Destination: dest, Text: "hi"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fs.channels[len(fs.channels)-1]; got != "C0GENERAL" {
+				t.Errorf("unexpected destination for \"%s\", got \"%s\" expected \"C0GENERAL\"", dest, got)
+			}
+		}
+		// the channel list is cached
+		if fs.calls["conversations.list"] != 1 {
+			t.Errorf("expected 1 channel list call, got %d", fs.calls["conversations.list"])
+		}
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		err = sndr.ValidateDestination("#doesnotexist")
+		expected := "channel_not_found: \"#doesnotexist\""
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error \"%s\", got %v", expected, err)
+		}
+	})
+
+	t.Run("user id", func(t *testing.T) {
+		calls := fs.calls["conversations.list"] + fs.calls["users.list"]
+		err = sndr.SendMessage(&sender.Message{Destination: "U0ALICE01", Text: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fs.channels[len(fs.channels)-1]; got != "U0ALICE01" {
+			t.Errorf("unexpected destination, got \"%s\" expected \"U0ALICE01\"", got)
+		}
+		if fs.calls["conversations.list"]+fs.calls["users.list"] != calls {
+			t.Errorf("expected the user id to be sent without lookup, got calls: %v", fs.calls)
+		}
+	})
+}
+
+func TestSlackSender_ConcurrentResolve(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"users.list":         `{"ok":true,"members":[{"id":"U02","name":"bob"}]}`,
+		"conversations.open": `{"ok":true,"channel":{"id":"D-DM"}}`,
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"}]}`,
+	})
+	defer fs.close()
+	release := make(chan struct{})
+	fs.hold = map[string]chan struct{}{"users.list": release}
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:   config.ModeDirectCli,
+		ApiUrl: fs.url(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user list request hangs, messages to channels must not wait for it
+	userDone := make(chan error)
+	go func() {
+		userDone <- sndr.SendMessage(&sender.Message{Destination: "@bob", Text: "hi"})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	channelDone := make(chan error)
+	go func() {
+		channelDone <- sndr.SendMessage(&sender.Message{Destination: "#general", Text: "hi"})
+	}()
+	select {
+	case err := <-channelDone:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("the message to the channel waited for the user lookup")
+	}
+
+	close(release)
+	if err := <-userDone; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlackSender_ChannelListFailure(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":false,"error":"missing_scope"}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:   config.ModeDirectCli,
+		ApiUrl: fs.url(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = sndr.SendMessage(&sender.Message{Destination: "#general", Text: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fs.channels[2] != "#general" {
+		t.Errorf("expected the channel name to be sent unresolved, got \"%s\"", fs.channels[2])
+	}
+	// after a failed attempt the channel list is not requested for every message
+	if fs.calls["conversations.list"] != 1 {
+		t.Errorf("expected 1 channel list call, got %d", fs.calls["conversations.list"])
+	}
+
+	err = sndr.ValidateDestination("#general")
+	if !errors.Is(err, sender.ErrChannelUnverified) {
+		t.Errorf("expected the channel to be reported as unverified, got: %v", err)
+	}
+}
+
+func TestSlackSender_FallbackChannel(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"},{"id":"C0FALLBACK","name":"fallback"}]}`,
+	})
+	defer fs.close()
+
+	t.Run("deliver to fallback", func(t *testing.T) {
+		sndr, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode:            config.ModeDirectCli,
+			ApiUrl:          fs.url(),
+			FallbackChannel: "fallback",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sndr.SendMessage(&sender.Message{Destination: "typo", Text: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fs.channels[len(fs.channels)-1]; got != "C0FALLBACK" {
+			t.Errorf("unexpected destination, got \"%s\" expected \"C0FALLBACK\"", got)
+		}
+	})
+
+	t.Run("no fallback configured", func(t *testing.T) {
+		sndr, err := sender.NewSlackSender(&config.ClientConfig{
+			Mode:   config.ModeDirectCli,
+			ApiUrl: fs.url(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sndr.SendMessage(&sender.Message{Destination: "typo", Text: "hi"})
+		expected := "channel_not_found"
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing \"%s\", got %v", expected, err)
+		}
+	})
+}
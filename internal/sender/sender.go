@@ -1,26 +1,64 @@
 package sender
 
-import "strings"
+import (
+	"strings"
+	"sync"
+	"time"
+)
 
 type MessageSender interface {
 	SendMessage(msg *Message) error
 	SendError(err error)
 }
 
+// DummyMessageSender records the messages sent, used in tests
 type DummyMessageSender struct {
-	Msg string
+	mutex sync.Mutex
+	Msg   string
+	Last  Message      // the last message sent
+	sent  chan Message // messages not yet returned by Next
 }
 
 func (sndr *DummyMessageSender) SendMessage(msg *Message) error {
+	sndr.mutex.Lock()
+	sndr.Last = *msg
 	s := strings.Trim(msg.Text, "\n")
 	s = strings.TrimSpace(s)
 
 	if s != "" {
 		sndr.Msg = sndr.Msg + "|" + s
 	}
+	sent := sndr.messages()
+	sndr.mutex.Unlock()
 
+	select {
+	case sent <- *msg:
+	default:
+	}
 	return nil
 }
+
+// Next waits up to timeout for the next message sent, returns false if no message was sent in time
+func (sndr *DummyMessageSender) Next(timeout time.Duration) (Message, bool) {
+	sndr.mutex.Lock()
+	sent := sndr.messages()
+	sndr.mutex.Unlock()
+
+	select {
+	case msg := <-sent:
+		return msg, true
+	case <-time.After(timeout):
+		return Message{}, false
+	}
+}
+
+// messages returns the channel of the sent messages, the mutex must be held
+func (sndr *DummyMessageSender) messages() chan Message {
+	if sndr.sent == nil {
+		sndr.sent = make(chan Message, 100)
+	}
+	return sndr.sent
+}
 func (sndr *DummyMessageSender) SendError(err error) {
 	msg := Message{
 		Text:  err.Error(),
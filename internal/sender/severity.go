@@ -0,0 +1,109 @@
+package sender
+
+import (
+	"send2slack/internal/config"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+// default representation of every severity, can be overwritten in the configuration
+var defaultSeverities = map[string]config.Severity{
+	SeverityInfo: {
+		Color: "blue",
+		Emoji: ":information_source:",
+	},
+	SeverityWarning: {
+		Color: "orange",
+		Emoji: ":warning:",
+	},
+	SeverityError: {
+		Color: "red",
+		Emoji: ":x:",
+	},
+	SeverityCritical: {
+		Color: "red",
+		Emoji: ":rotating_light:",
+	},
+}
+
+// IsValidSeverity returns true if the severity is empty or one of the known levels
+func IsValidSeverity(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, ok := defaultSeverities[s]
+	return ok
+}
+
+// getSeverity merges the default values of a severity with the configured ones
+func getSeverity(s string, configured map[string]config.Severity) config.Severity {
+
+	sev := defaultSeverities[s]
+	if c, ok := configured[s]; ok {
+		if c.Channel != "" {
+			sev.Channel = c.Channel
+		}
+		if c.Color != "" {
+			sev.Color = c.Color
+		}
+		if c.Emoji != "" {
+			sev.Emoji = c.Emoji
+		}
+		if c.Mention != "" {
+			sev.Mention = c.Mention
+		}
+	}
+	return sev
+}
+
+// mentionText returns the slack markup to notify a mention,
+// accepted values are "here", "channel", "everyone" or the id of a user group
+func mentionText(mention string) string {
+	switch mention {
+	case "":
+		return ""
+	case "here", "channel", "everyone":
+		return "<!" + mention + ">"
+	default:
+		return "<!subteam^" + strings.TrimPrefix(mention, "@") + ">"
+	}
+}
+
+// severityFromMailHeaders derives the severity from the headers "x-slack-severity", "x-priority" and "importance"
+// returns empty string if the mail does not have a priority, unknown severities are ignored so the mail is not rejected
+func severityFromMailHeaders(headers map[string]string) string {
+
+	if s := strings.ToLower(strings.TrimSpace(getMapString(headers, "x-slack-severity"))); s != "" && IsValidSeverity(s) {
+		return s
+	}
+
+	// X-Priority is a number from 1 (highest) to 5 (lowest), optionally followed by a description
+	if p := getMapString(headers, "x-priority"); p != "" {
+		prio, err := strconv.Atoi(strings.Fields(p)[0])
+		if err == nil {
+			switch {
+			case prio <= 1:
+				return SeverityCritical
+			case prio == 2:
+				return SeverityError
+			case prio >= 4:
+				return SeverityInfo
+			}
+		}
+	}
+
+	switch strings.ToLower(getMapString(headers, "importance")) {
+	case "high":
+		return SeverityError
+	case "low":
+		return SeverityInfo
+	}
+	return ""
+}
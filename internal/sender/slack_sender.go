@@ -5,41 +5,74 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"os/user"
 	"send2slack/internal/config"
+	"strings"
 	"text/template"
 	"time"
 )
 
 type SlackSender struct {
-	// todo add destination for error sending
-	client             *slack.Client
-	mode               config.Mode
-	url                *url.URL
-	emailTemplate      string
-	defaultDestination string
+	client              *slack.Client
+	mode                config.Mode
+	url                 *url.URL
+	emailTemplate       string
+	defaultDestination  string
+	fallbackDestination string // messages that cannot be delivered are sent here
+	severities          map[string]config.Severity
+	resolver            *destinationResolver
+	threads             *threadStore
+	httpClient          *http.Client // used to reach a send2slack server
+	requestUrl          string
+	uploadOverflow      bool // upload the complete text of truncated messages as file in the thread
 }
 
 type slackMessage struct {
 	Message
-	att *slack.Attachment
+	att      *slack.Attachment
+	overflow string // complete text of a truncated message, uploaded as file
 }
 
 func NewSlackSender(cfg *config.ClientConfig) (*SlackSender, error) {
 
+	var httpClient *http.Client
+	var requestUrl string
 	if cfg.Mode == config.ModeHttpClient {
 		if cfg.Url == nil {
 			return nil, fmt.Errorf("url cannot be empty")
 		}
+		var err error
+		httpClient, requestUrl, err = newHttpClient(cfg.Url, cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	var opts []slack.Option
+	if cfg.ApiUrl != "" {
+		opts = append(opts, slack.OptionAPIURL(cfg.ApiUrl))
+	}
+	client := slack.New(cfg.Token, opts...)
+
 	sl := SlackSender{
-		client:             slack.New(cfg.Token),
-		mode:               cfg.Mode,
-		url:                cfg.Url,
-		defaultDestination: cfg.DefChannel,
+		client:              client,
+		mode:                cfg.Mode,
+		url:                 cfg.Url,
+		defaultDestination:  cfg.DefChannel,
+		fallbackDestination: cfg.FallbackChannel,
+		severities:          cfg.Severities,
+		resolver:            newDestinationResolver(client),
+		threads:             newThreadStore(),
+		httpClient:          httpClient,
+		requestUrl:          requestUrl,
+		uploadOverflow:      cfg.UploadOverflow,
 	}
 	return &sl, nil
 }
@@ -60,7 +93,11 @@ func (c *SlackSender) SendMessage(msg *Message) error {
 			return err
 		}
 
-		return c.sendMsgDirecCli(slkMsg)
+		err = c.sendMsgDirecCli(slkMsg)
+		if err != nil && c.fallbackDestination != "" && slkMsg.Destination != c.fallbackDestination {
+			return c.sendFallback(slkMsg, err)
+		}
+		return err
 	case config.ModeHttpClient:
 		return c.sendMsgHttpClient(msg)
 
@@ -69,6 +106,54 @@ func (c *SlackSender) SendMessage(msg *Message) error {
 	}
 }
 
+// ValidateDestination verifies that a destination can be resolved using the slack api, returns
+// ErrChannelUnverified if the channel list is not available
+func (c *SlackSender) ValidateDestination(dest string) error {
+	_, err := c.resolver.validate(dest)
+	return err
+}
+
+// TestConnection verifies the token with the slack api in direct mode, or that the send2slack server is
+// reachable in client mode, no message is sent
+func (c *SlackSender) TestConnection() (string, error) {
+
+	if c.mode == config.ModeHttpClient {
+		resp, err := c.httpClient.Get(c.requestUrl)
+		if err != nil {
+			return "", fmt.Errorf("send2slack server not reachable: %v", err)
+		}
+		resp.Body.Close()
+		return fmt.Sprintf("send2slack server %s is reachable", c.url), nil
+	}
+
+	res, err := c.client.AuthTest()
+	if err != nil {
+		return "", fmt.Errorf("slack authentication failed: %v", err)
+	}
+	return fmt.Sprintf("authenticated as \"%s\" in team \"%s\"", res.User, res.Team), nil
+}
+
+// sendFallback delivers a message that could not be sent to the fallback destination
+// together with the reason why the original delivery failed
+func (c *SlackSender) sendFallback(msg *slackMessage, sendErr error) error {
+
+	notice := fmt.Sprintf("*undelivered message for \"%s\"*: %s", msg.Destination, strings.TrimSpace(sendErr.Error()))
+	fallback := *msg
+	fallback.Destination = c.fallbackDestination
+	if fallback.Text != "" {
+		fallback.Text = notice + "\n" + fallback.Text
+	} else {
+		fallback.Text = notice
+	}
+
+	err := c.sendMsgDirecCli(&fallback)
+	if err != nil {
+		return fmt.Errorf("%s, fallback delivery failed: %w", strings.TrimSpace(sendErr.Error()), err)
+	}
+	log.Warnf("message for \"%s\" delivered to fallback channel \"%s\": %v", msg.Destination, c.fallbackDestination, sendErr)
+	return nil
+}
+
 // SendError send an error to the default destination
 func (c *SlackSender) SendError(err error) {
 	msg := Message{
@@ -80,7 +165,7 @@ func (c *SlackSender) SendError(err error) {
 
 // default template used to generate the slack message based on an email
 //const DefaultMailTemplate = `*[EMAIL]* from: _ {{ index .Meta "from" }} _ ` + "```" + `{{ .Text }}` + "```"
-const DefaultMailTemplate = `*[EMAIL]* 
+const DefaultMailTemplate = `*[EMAIL]*{{ if .Host }} [{{ .Host }}]{{ end }}{{ if .User }} mail from {{ .User }}{{ end }} 
 From: _ {{ index .Meta "from" }} _ 
 To:  _ {{ index .Meta "to" }} _ 
 Date: _ {{ .Date }} _ 
@@ -98,17 +183,45 @@ func (c *SlackSender) transformMsg(msg *Message) (*slackMessage, error) {
 		att: &slack.Attachment{},
 	}
 
+	// apply the representation of the severity level
+	var prefix string
+	if msg.Severity != "" {
+		sev := getSeverity(msg.Severity, c.severities)
+		if msg.Destination == "" {
+			msg.Destination = sev.Channel
+		}
+		if msg.Color == "" {
+			msg.Color = sev.Color
+		}
+		if !msg.Resolved {
+			prefix = strings.TrimSpace(sev.Emoji + " " + mentionText(sev.Mention))
+		}
+	}
+
 	if msg.Destination == "" {
 		msg.Destination = c.defaultDestination
 	}
 	slkMsg.Destination = msg.Destination
 
 	slkMsg.Debug = msg.Debug
+	slkMsg.ThreadKey = msg.ThreadKey
+	slkMsg.Update = msg.Update
 	slkMsg.Meta = msg.Meta
+	slkMsg.File = msg.File
+
+	// long texts are cut on line boundaries, the complete text is optionally uploaded
+	if text, lines := truncateLines(msg.Text, maxTextLength); lines > 0 {
+		if c.uploadOverflow {
+			slkMsg.overflow = msg.Text
+		}
+		m := *msg
+		m.Text = text + truncationNotice(lines)
+		msg = &m
+	}
 	slkMsg.Text = msg.Text
 
-	switch msg.origin {
-	case "email":
+	switch msg.Origin {
+	case OriginEmail:
 
 		if c.emailTemplate == "" {
 			c.emailTemplate = DefaultMailTemplate
@@ -132,8 +245,8 @@ func (c *SlackSender) transformMsg(msg *Message) (*slackMessage, error) {
 		break
 	default:
 
-		// if color is defined send the message as attachment
-		if msg.getColor() != "" {
+		// if color is defined send the message as attachment, the comment of a file is always plain text
+		if msg.getColor() != "" && msg.File == nil {
 			slkMsg.att.Text = msg.Text
 			slkMsg.att.Color = msg.getColor()
 			slkMsg.Text = ""
@@ -142,6 +255,13 @@ func (c *SlackSender) transformMsg(msg *Message) (*slackMessage, error) {
 		break
 	}
 
+	// the prefix is added to the main text, mentions in attachments don't notify
+	if prefix != "" && slkMsg.Text != "" {
+		slkMsg.Text = prefix + " " + slkMsg.Text
+	} else if prefix != "" {
+		slkMsg.Text = prefix
+	}
+
 	return &slkMsg, nil
 
 }
@@ -149,45 +269,187 @@ func (c *SlackSender) transformMsg(msg *Message) (*slackMessage, error) {
 // internal method to send a message directly using the slack api
 func (c *SlackSender) sendMsgDirecCli(msg *slackMessage) error {
 
-	var err error
+	// translate user destinations into their direct message conversation
+	destination, err := c.resolver.resolve(msg.Destination)
+	if err != nil {
+		return fmt.Errorf("error sending slack message: %w\n", err)
+	}
+
+	opts := []slack.MsgOption{slack.MsgOptionText(msg.Text, false)}
 	if msg.att != nil {
-		_, _, err = c.client.PostMessage(msg.Destination, slack.MsgOptionText(msg.Text, false),
-			slack.MsgOptionAttachments(*msg.att))
+		opts = append(opts, slack.MsgOptionAttachments(*msg.att))
+	}
+
+	// reply in the thread of the first message sent with the same thread key, or replace that message
+	thread, inThread := c.threads.get(msg.ThreadKey)
+	if inThread && msg.Update && msg.File == nil {
+		return c.updateMsgDirectCli(msg, thread, opts)
+	}
+	if inThread {
+		destination = thread.channel
+		opts = append(opts, slack.MsgOptionTS(thread.ts))
+	}
+
+	if msg.File != nil {
+		return c.uploadFile(msg, destination, thread.ts)
+	}
+
+	channel, ts, err := c.client.PostMessage(destination, opts...)
+	if err != nil {
+		return fmt.Errorf("error sending slack message: %w\n", err)
+	}
+
+	if !inThread {
+		c.threads.set(msg.ThreadKey, channel, ts)
 	} else {
-		_, _, err = c.client.PostMessage(msg.Destination, slack.MsgOptionText(msg.Text, false))
+		ts = thread.ts
+	}
+
+	if msg.overflow != "" {
+		c.uploadOverflowText(msg.overflow, channel, ts)
+	}
+	return nil
+}
+
+// updateMsgDirectCli replaces the text of a message posted before
+func (c *SlackSender) updateMsgDirectCli(msg *slackMessage, thread postedMessage, opts []slack.MsgOption) error {
+
+	// without attachment the attachments of the original message are removed
+	if msg.att == nil {
+		opts = append(opts, slack.MsgOptionAttachments([]slack.Attachment{}...))
 	}
 
+	_, _, _, err := c.client.UpdateMessage(thread.channel, thread.ts, opts...)
 	if err != nil {
-		return fmt.Errorf("error sending slack message: %s\n", err)
+		return fmt.Errorf("error updating slack message: %w\n", err)
 	}
 
+	if msg.overflow != "" {
+		c.uploadOverflowText(msg.overflow, thread.channel, thread.ts)
+	}
 	return nil
 }
 
-// internal method to send a message to a send2slack server
-func (c *SlackSender) sendMsgHttpClient(msg *Message) error {
+// uploadOverflowText uploads the complete text of a truncated message to the thread of the message,
+// the message was already delivered so failures are only logged
+func (c *SlackSender) uploadOverflowText(text string, channel string, ts string) {
+	_, err := c.client.UploadFileV2(slack.UploadFileV2Parameters{
+		Content:         text,
+		FileSize:        len(text),
+		SnippetType:     "text",
+		Filename:        "message.txt",
+		Title:           "complete message",
+		Channel:         channel,
+		ThreadTimestamp: ts,
+	})
+	if err != nil {
+		log.Warnf("unable to upload the complete message: %v", err)
+	}
+}
+
+// SubmitError is returned if the send2slack server did not accept a message
+type SubmitError struct {
+	StatusCode int
+	Message    string // response of the server
+}
+
+func (e *SubmitError) Error() string {
+	if e.Message != "" {
+		return "message not submitted: " + e.Message
+	}
+	return "message not submitted"
+}
+
+// slack api errors that can go away by sending the message again
+var transientSlackErrors = map[string]bool{
+	"internal_error":      true,
+	"fatal_error":         true,
+	"service_unavailable": true,
+	"request_timeout":     true,
+	"ratelimited":         true,
+}
+
+// slack api errors caused by the token, the message can be sent once the token is replaced
+var authSlackErrors = map[string]bool{
+	"invalid_auth":     true,
+	"not_authed":       true,
+	"token_revoked":    true,
+	"token_expired":    true,
+	"account_inactive": true,
+}
+
+// IsRejected reports whether slack or the send2slack server refused the message, i.e. because of
+// channel_not_found, sending the message again fails the same way. errors of the token are not rejections,
+// the message can be sent again once the token is fixed
+func IsRejected(err error) bool {
+	var submitErr *SubmitError
+	if errors.As(err, &submitErr) {
+		return submitErr.StatusCode >= 400 && submitErr.StatusCode < 500
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return !transientSlackErrors[slackErr.Err] && !authSlackErrors[slackErr.Err]
+	}
+	return false
+}
+
+// IsAuthError reports whether slack refused the token, i.e. invalid_auth or token_revoked
+func IsAuthError(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	return errors.As(err, &slackErr) && authSlackErrors[slackErr.Err]
+}
+
+// newJsonRequest creates the request sending a message as json to a send2slack server
+func newJsonRequest(requestUrl string, msg *Message) (*http.Request, error) {
 
 	jsonMsg, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", c.url.String(), bytes.NewBuffer(jsonMsg))
+	req, err := http.NewRequest("POST", requestUrl, bytes.NewBuffer(jsonMsg))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// internal method to send a message to a send2slack server
+func (c *SlackSender) sendMsgHttpClient(msg *Message) error {
+
+	// report where the message comes from, the user of a mail is the sender in the headers
+	m := *msg
+	if m.Host == "" {
+		m.Host, _ = os.Hostname()
+	}
+	if m.User == "" && m.Origin != OriginEmail {
+		if u, err := user.Current(); err == nil {
+			m.User = u.Username
+		}
+	}
+
+	var req *http.Request
+	var err error
+	if m.File != nil {
+		req, err = newUploadRequest(c.requestUrl, &m)
+	} else {
+		req, err = newJsonRequest(c.requestUrl, &m)
+	}
+	if err != nil {
+		return err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("message not submitted")
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &SubmitError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
 	}
 
 	return nil
@@ -102,3 +102,323 @@ func TestNewSlackSenderClient(t *testing.T) {
 		})
 	}
 }
+
+func TestSlackSender_Severity(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"},{"id":"C0OPS","name":"ops"}]}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		ApiUrl:     fs.url(),
+		DefChannel: "general",
+		Severities: map[string]config.Severity{
+			sender.SeverityCritical: {
+				Channel: "ops",
+				Mention: "here",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		name            string
+		msg             sender.Message
+		expectedChannel string
+		expectedText    string
+	}{
+		{
+			name:            "no severity",
+			msg:             sender.Message{Text: "text"},
+			expectedChannel: "C0GENERAL",
+			expectedText:    "text",
+		},
+		{
+			name:            "default representation",
+			msg:             sender.Message{Text: "text", Severity: sender.SeverityWarning},
+			expectedChannel: "C0GENERAL",
+			expectedText:    ":warning:",
+		},
+		{
+			name:            "configured channel and mention",
+			msg:             sender.Message{Text: "text", Severity: sender.SeverityCritical},
+			expectedChannel: "C0OPS",
+			expectedText:    ":rotating_light: <!here>",
+		},
+		{
+			name:            "resolved alert",
+			msg:             sender.Message{Text: "text", Severity: sender.SeverityCritical, Resolved: true},
+			expectedChannel: "C0OPS",
+			expectedText:    "",
+		},
+		{
+			name:            "explicit destination wins",
+			msg:             sender.Message{Text: "text", Severity: sender.SeverityCritical, Destination: "general"},
+			expectedChannel: "C0GENERAL",
+			expectedText:    ":rotating_light: <!here>",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := sndr.SendMessage(&tc.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := len(fs.channels) - 1
+			if fs.channels[last] != tc.expectedChannel {
+				t.Errorf("unexpected channel, got \"%s\" expected \"%s\"", fs.channels[last], tc.expectedChannel)
+			}
+			if fs.texts[last] != tc.expectedText {
+				t.Errorf("unexpected text, got \"%s\" expected \"%s\"", fs.texts[last], tc.expectedText)
+			}
+		})
+	}
+}
+
+func TestSlackSender_Threads(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"},{"id":"C0OPS","name":"ops"}]}`,
+		"chat.postMessage":   `{"ok":true,"channel":"C0OPS","ts":"1000.1"}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		ApiUrl:     fs.url(),
+		DefChannel: "general",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []sender.Message{
+		{Text: "firing", Destination: "ops", ThreadKey: "group1"},
+		{Text: "resolved", ThreadKey: "group1"},
+		{Text: "other", ThreadKey: "group2"},
+	}
+	for _, msg := range msgs {
+		err := sndr.SendMessage(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectedChannels := []string{"C0OPS", "C0OPS", "C0GENERAL"}
+	expectedThreads := []string{"", "1000.1", ""}
+	for i := range msgs {
+		if fs.channels[i] != expectedChannels[i] {
+			t.Errorf("message %d: unexpected channel, got \"%s\" expected \"%s\"", i, fs.channels[i], expectedChannels[i])
+		}
+		if fs.threads[i] != expectedThreads[i] {
+			t.Errorf("message %d: unexpected thread, got \"%s\" expected \"%s\"", i, fs.threads[i], expectedThreads[i])
+		}
+	}
+}
+
+func TestSlackSender_Truncate(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"}]}`,
+		"chat.postMessage":   `{"ok":true,"channel":"C0GENERAL","ts":"1000.1"}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:           config.ModeDirectCli,
+		ApiUrl:         fs.url(),
+		DefChannel:     "general",
+		UploadOverflow: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	long := strings.Repeat(line, 100)
+
+	err = sndr.SendMessage(&sender.Message{Text: long})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.TrimSuffix(strings.Repeat(line, 38), "\n") + "\n…truncated 62 lines"
+	if fs.texts[0] != expected {
+		t.Errorf("unexpected truncated text, got %d characters ending with: \"%s\"", len(fs.texts[0]), fs.texts[0][len(fs.texts[0])-30:])
+	}
+
+	expectedUpload := long + "|1000.1"
+	if len(fs.uploads) != 1 || fs.uploads[0] != expectedUpload {
+		t.Errorf("expected the complete text to be uploaded in the thread, got %d uploads", len(fs.uploads))
+	}
+	if fs.calls["files.upload"] != 0 || len(fs.snippets) != 1 || fs.snippets[0] != "text" {
+		t.Errorf("expected the text to be uploaded as snippet with the external upload, got calls: %v", fs.calls)
+	}
+
+	t.Run("short message", func(t *testing.T) {
+		err = sndr.SendMessage(&sender.Message{Text: "short"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fs.texts[1] != "short" || len(fs.uploads) != 1 {
+			t.Errorf("unexpected text \"%s\" or upload", fs.texts[1])
+		}
+	})
+}
+
+func TestSlackSender_Snippet(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0GENERAL","name":"general"}]}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		ApiUrl:     fs.url(),
+		DefChannel: "general",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sndr.SendMessage(&sender.Message{
+		Text: "build log",
+		File: &sender.File{Name: "build.log", Snippet: true, Content: []byte("make: *** [all] Error 1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.calls["chat.postMessage"] != 0 {
+		t.Errorf("expected no message to be posted, got %d", fs.calls["chat.postMessage"])
+	}
+	expected := []string{"make: *** [all] Error 1|"}
+	if diff := cmp.Diff(expected, fs.uploads); diff != "" {
+		t.Errorf("unexpected uploads (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"text"}, fs.snippets); diff != "" {
+		t.Errorf("unexpected snippet types (-want +got):\n%s", diff)
+	}
+
+	t.Run("regular file", func(t *testing.T) {
+		err = sndr.SendMessage(&sender.Message{
+			Text: "report",
+			File: &sender.File{Name: "report.csv", Content: []byte("a,b")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fs.uploads) != 2 || fs.uploads[1] != "a,b|" || fs.snippets[1] != "" {
+			t.Errorf("unexpected uploads %v with snippet types %v", fs.uploads, fs.snippets)
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		err = sndr.SendMessage(&sender.Message{File: &sender.File{Name: "empty.txt"}})
+		if err == nil || err.Error() != sender.EmptyFileError {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSlackSender_Update(t *testing.T) {
+
+	fs := newFakeSlack(map[string]string{
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0OPS","name":"ops"}]}`,
+		"chat.postMessage":   `{"ok":true,"channel":"C0OPS","ts":"1000.1"}`,
+		"chat.update":        `{"ok":true,"channel":"C0OPS","ts":"1000.1"}`,
+	})
+	defer fs.close()
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:   config.ModeDirectCli,
+		ApiUrl: fs.url(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []sender.Message{
+		{Text: "backup started", Destination: "ops", ThreadKey: "exec1"},
+		{Text: "backup succeeded", Destination: "ops", ThreadKey: "exec1", Update: true},
+	}
+	for _, msg := range msgs {
+		err := sndr.SendMessage(&msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fs.calls["chat.postMessage"] != 1 || fs.calls["chat.update"] != 1 {
+		t.Errorf("expected one posted and one updated message, got calls: %v", fs.calls)
+	}
+	expectedTexts := []string{"backup started", "backup succeeded"}
+	if diff := cmp.Diff(expectedTexts, fs.texts); diff != "" {
+		t.Errorf("unexpected texts (-want +got):\n%s", diff)
+	}
+}
+
+func TestSlackSender_Preview(t *testing.T) {
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		DefChannel: "general",
+		Severities: map[string]config.Severity{
+			sender.SeverityCritical: {
+				Channel: "ops",
+				Mention: "here",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, text, err := sndr.Preview(&sender.Message{Text: "disk full\non web-03", Severity: sender.SeverityCritical})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dest != "ops" {
+		t.Errorf("unexpected destination, got \"%s\" expected \"ops\"", dest)
+	}
+	expected := ":rotating_light: <!here>\n[color #FF5640]\n┃ disk full\n┃ on web-03\n"
+	if text != expected {
+		t.Errorf("unexpected preview, got \"%s\" expected \"%s\"", text, expected)
+	}
+
+	_, _, err = sndr.Preview(&sender.Message{})
+	if err == nil || err.Error() != sender.EmptyBodyError {
+		t.Errorf("expected an empty message to be rejected, got: %v", err)
+	}
+}
+
+func TestSlackSender_MailSource(t *testing.T) {
+
+	sndr, err := sender.NewSlackSender(&config.ClientConfig{
+		Mode:       config.ModeDirectCli,
+		DefChannel: "general",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, text, err := sndr.Preview(&sender.Message{
+		Text:   "backup done",
+		Origin: sender.OriginEmail,
+		Host:   "web-03",
+		User:   "root",
+		Meta:   map[string]string{"subject": "cron"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "*[EMAIL]* [web-03] mail from root \n") {
+		t.Errorf("expected the host and the user in the first line, got \"%s\"", text)
+	}
+}
@@ -0,0 +1,206 @@
+package sender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maximum amount of messages kept in the spool, newer messages are rejected
+const maxSpoolSize = 10000
+
+// spooled messages are discarded once they are older than maxSpoolAge, or once the upstream server
+// answered maxSpoolAttempts times with an error, so that a single message cannot block the spool.
+// 503 answers are not counted, the upstream server is not able to send any message, i.e. its token is revoked
+const (
+	maxSpoolAge      = 24 * time.Hour
+	maxSpoolAttempts = 10
+)
+
+// spool keeps messages on disk, one json file per message, the file names keep the order of the messages.
+// mutex protects the files and is never held while sending, flushMutex makes sure only one flush runs
+type spool struct {
+	mutex      sync.Mutex
+	flushMutex sync.Mutex
+	dir        string
+	attempts   map[string]int // failed attempts per file, only counted if the upstream server answered
+}
+
+var (
+	spoolsMutex sync.Mutex
+	spools      = map[string]*spool{}
+	spoolSeq    uint32
+)
+
+// openSpool returns the spool of a directory, senders using the same directory share the instance
+// so that messages are not sent twice
+func openSpool(dir string) (*spool, error) {
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolsMutex.Lock()
+	defer spoolsMutex.Unlock()
+
+	if s, ok := spools[dir]; ok {
+		return s, nil
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create spool directory: %v", err)
+	}
+	s := &spool{dir: dir, attempts: map[string]int{}}
+	spools[dir] = s
+	return s, nil
+}
+
+// files returns the spooled messages, oldest first
+func (s *spool) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// push writes a message to the spool
+func (s *spool) push(msg *Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write(msg)
+}
+
+// pushIfWaiting spools the message if older messages are waiting, so that the order is kept,
+// returns false if the spool is empty and the message can be sent directly
+func (s *spool) pushIfWaiting(msg *Message) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		return false, err
+	}
+	if len(files) == 0 {
+		return false, nil
+	}
+	return true, s.write(msg)
+}
+
+// write adds a message file to the spool, the caller must hold the lock
+func (s *spool) write(msg *Message) error {
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	if len(files) >= maxSpoolSize {
+		return fmt.Errorf("spool is full, %d messages are waiting", len(files))
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%020d-%010d", time.Now().UnixNano(), atomic.AddUint32(&spoolSeq, 1))
+	tmp := filepath.Join(s.dir, name+".tmp")
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	// the rename makes sure only complete messages are picked up
+	return os.Rename(tmp, filepath.Join(s.dir, name+".json"))
+}
+
+// flush sends the spooled messages in order until sending fails, returns the amount of messages still waiting
+func (s *spool) flush(send func(msg *Message) error) (int, error) {
+
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	files, err := s.files()
+	s.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		if spooledAt(name).Before(time.Now().Add(-maxSpoolAge)) {
+			log.Warnf("discarding spooled message %s: not delivered within %s", name, maxSpoolAge)
+			s.remove(file)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return s.pending(), err
+		}
+
+		var msg Message
+		err = json.Unmarshal(data, &msg)
+		if err == nil {
+			err = send(&msg)
+		}
+
+		var submitErr *SubmitError
+		if err != nil && !isPermanentError(err) {
+			if !errors.As(err, &submitErr) || submitErr.StatusCode == http.StatusServiceUnavailable {
+				return s.pending(), err
+			}
+			s.mutex.Lock()
+			s.attempts[name]++
+			attempts := s.attempts[name]
+			s.mutex.Unlock()
+			if attempts < maxSpoolAttempts {
+				return s.pending(), err
+			}
+		}
+		// messages that can never be delivered are discarded to not block the spool
+		if err != nil {
+			log.Warnf("discarding spooled message %s: %v", name, err)
+		}
+		s.remove(file)
+	}
+	return s.pending(), nil
+}
+
+// pending returns the amount of spooled messages, including the ones spooled during a flush
+func (s *spool) pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files, _ := s.files()
+	return len(files)
+}
+
+// remove deletes a spooled message
+func (s *spool) remove(file string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	os.Remove(file)
+	delete(s.attempts, filepath.Base(file))
+}
+
+// spooledAt returns the time a message was spooled from its file name
+func spooledAt(name string) time.Time {
+	nano, err := strconv.ParseInt(strings.SplitN(name, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(0, nano)
+}
@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"sync"
+	"time"
+)
+
+// time after which a thread is forgotten, later messages with the same key start a new thread
+const threadTtl = 7 * 24 * time.Hour
+
+type postedMessage struct {
+	channel string
+	ts      string
+	posted  time.Time
+}
+
+// threadStore keeps track of the first message posted for a thread key, so that later messages
+// with the same key can be posted as replies in the thread of that message
+type threadStore struct {
+	mutex   sync.Mutex
+	threads map[string]postedMessage
+}
+
+func newThreadStore() *threadStore {
+	return &threadStore{
+		threads: map[string]postedMessage{},
+	}
+}
+
+// get returns the message that started the thread, the second value is false if the thread is unknown
+func (s *threadStore) get(key string) (postedMessage, bool) {
+	if key == "" {
+		return postedMessage{}, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m, ok := s.threads[key]
+	if ok && time.Since(m.posted) > threadTtl {
+		delete(s.threads, key)
+		return postedMessage{}, false
+	}
+	return m, ok
+}
+
+// set stores the message that started a thread and forgets expired threads
+func (s *threadStore) set(key string, channel string, ts string) {
+	if key == "" {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for k, m := range s.threads {
+		if time.Since(m.posted) > threadTtl {
+			delete(s.threads, k)
+		}
+	}
+	s.threads[key] = postedMessage{
+		channel: channel,
+		ts:      ts,
+		posted:  time.Now(),
+	}
+}
@@ -0,0 +1,55 @@
+package sender
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maximum amount of characters of a message text, slack truncates longer texts and attachments
+// are collapsed, the email template and the severity prefix need some additional space
+const maxTextLength = 3800
+
+// truncateLines shortens the text to max characters cutting on line boundaries, the second value is
+// the amount of lines that were removed or cut, 0 if the text was not truncated
+func truncateLines(text string, max int) (string, int) {
+
+	if utf8.RuneCountInString(text) <= max {
+		return text, 0
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	var sb strings.Builder
+	length := 0
+	kept := 0
+	for _, line := range lines {
+		n := utf8.RuneCountInString(line)
+		if length+n > max {
+			break
+		}
+		sb.WriteString(line)
+		length += n
+		kept++
+	}
+
+	// a single line longer than the limit is cut
+	if kept == 0 {
+		runes := []rune(lines[0])
+		sb.WriteString(string(runes[:max]))
+	}
+
+	truncated := len(lines) - kept
+	// the last element is empty if the text ends with a new line
+	if lines[len(lines)-1] == "" {
+		truncated--
+	}
+	return strings.TrimRight(sb.String(), "\n"), truncated
+}
+
+// truncationNotice is appended to truncated texts
+func truncationNotice(lines int) string {
+	if lines == 1 {
+		return "\n…truncated 1 line"
+	}
+	return "\n…truncated " + strconv.Itoa(lines) + " lines"
+}
@@ -0,0 +1,309 @@
+package setup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"send2slack/internal/config"
+	"send2slack/internal/daemon"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ServiceUser runs the daemon, the server configuration is owned by this user
+const ServiceUser = "send2slack"
+
+// Answers are the values written to a new configuration file
+type Answers struct {
+	Token          string
+	DefaultChannel string
+	EmailChannel   string
+	RemoteUrl      string // client only, "false" to send directly with the token
+	ListenUrl      string // server only
+	MboxWatch      string // server only, "false" to disable
+}
+
+// DefaultAnswers returns the values used without prompting
+func DefaultAnswers() *Answers {
+	a := Answers{
+		DefaultChannel: "general",
+		EmailChannel:   "general",
+		RemoteUrl:      "false",
+		ListenUrl:      "127.0.0.1:" + strconv.Itoa(config.DefaultPort),
+		MboxWatch:      "false",
+	}
+	return &a
+}
+
+// Wizard asks for the configuration values on a terminal
+type Wizard struct {
+	in       *bufio.Reader
+	out      io.Writer
+	terminal int // file descriptor of the input if it is a terminal, -1 otherwise
+}
+
+func NewWizard(in io.Reader, out io.Writer) *Wizard {
+	w := Wizard{
+		in:       bufio.NewReader(in),
+		out:      out,
+		terminal: -1,
+	}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		w.terminal = int(f.Fd())
+	}
+	return &w
+}
+
+// Ask prints the question with the default value and returns the answer, the default if the answer is empty
+func (w *Wizard) Ask(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	answer, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", fmt.Errorf("no answer: %v", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// AskSecret asks for a value without echoing the answer if the input is a terminal
+func (w *Wizard) AskSecret(question string) (string, error) {
+	if w.terminal < 0 {
+		return w.Ask(question, "")
+	}
+	fmt.Fprintf(w.out, "%s: ", question)
+	answer, err := term.ReadPassword(w.terminal)
+	fmt.Fprintln(w.out)
+	if err != nil {
+		return "", fmt.Errorf("no answer: %v", err)
+	}
+	return strings.TrimSpace(string(answer)), nil
+}
+
+// AskValid asks again until the answer passes the validation
+func (w *Wizard) AskValid(question string, def string, validate func(string) error) (string, error) {
+	for {
+		answer, err := w.Ask(question, def)
+		if err != nil {
+			return "", err
+		}
+		err = validate(answer)
+		if err == nil {
+			return answer, nil
+		}
+		fmt.Fprintf(w.out, "  invalid value: %v\n", err)
+	}
+}
+
+// Confirm asks a yes or no question, only "y" and "yes" confirm
+func (w *Wizard) Confirm(question string) bool {
+	answer, err := w.Ask(question+" [y/N]", "")
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// Prompt asks for all the values of the client or the server configuration
+func (w *Wizard) Prompt(server bool) (*Answers, error) {
+
+	a := DefaultAnswers()
+	var err error
+
+	if server {
+		a.Token, err = w.AskSecret("slack token (xoxb-...)")
+	} else {
+		a.Token, err = w.AskSecret("slack token (xoxb-...), empty to send through a send2slack server")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !server && a.Token == "" {
+		a.RemoteUrl, err = w.Ask("send2slack server url", "127.0.0.1:"+strconv.Itoa(config.DefaultPort))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a.DefaultChannel, err = w.Ask("default channel", a.DefaultChannel)
+	if err != nil {
+		return nil, err
+	}
+	a.EmailChannel, err = w.Ask("channel for mails", a.DefaultChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	if server {
+		a.ListenUrl, err = w.AskValid("listen address of the server, <ip>:<port>, unix://<path> or false", a.ListenUrl, validateListen)
+		if err != nil {
+			return nil, err
+		}
+		a.MboxWatch, err = w.AskValid("mbox directory to watch, i.e. /var/mail, or false", a.MboxWatch, validateDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// validateListen accepts "false", a unix socket or an address the server can listen on
+func validateListen(listenUrl string) error {
+	if listenUrl == "false" {
+		return nil
+	}
+	if strings.HasPrefix(listenUrl, "unix://") {
+		if strings.TrimPrefix(listenUrl, "unix://") == "" {
+			return fmt.Errorf("expecting unix://<path>")
+		}
+		return nil
+	}
+	_, _, err := daemon.ParseListenAddress(listenUrl)
+	return err
+}
+
+// validateDir accepts "false" or an existing directory
+func validateDir(dir string) error {
+	if dir == "false" {
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// Render returns the commented configuration file with the answers
+func Render(server bool, a *Answers) (string, error) {
+
+	text := clientTemplate
+	if server {
+		text = serverTemplate
+	}
+	tmpl, err := template.New("config").Funcs(template.FuncMap{"quote": quote}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, a)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// quote returns the value as double quoted yaml string, json strings are valid yaml
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// DefaultPath returns the path of a new configuration file, /etc/send2slack for root, $HOME/.send2slack
+// for other users
+func DefaultPath(server bool) (string, error) {
+	name := "client.yaml"
+	if server {
+		name = "server.yaml"
+	}
+	if os.Geteuid() == 0 {
+		return filepath.Join("/etc/send2slack", name), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".send2slack", name), nil
+}
+
+// Write writes the configuration file, files with a token are only readable by the owner, the others by
+// all users so that they can use a shared client configuration. If send2slack runs as root and the owner
+// exists, the file is owned by that user, i.e. the service user of the daemon.
+// the content is written to a temporary file only readable by the current user which gets the owner and
+// the mode before it replaces the configuration, the token is never readable by other users
+func Write(path string, content string, secret bool, owner string) error {
+
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if secret {
+		mode = 0600
+	}
+
+	// TempFile creates the file with mode 0600
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = chown(tmp.Name(), owner)
+	if err != nil {
+		return fmt.Errorf("unable to set the owner of %s: %v", path, err)
+	}
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// chown sets the user and its group as owner of the file if send2slack runs as root and the user exists
+func chown(path string, owner string) error {
+	if owner == "" || os.Geteuid() != 0 {
+		return nil
+	}
+	u, err := user.Lookup(owner)
+	if _, ok := err.(user.UnknownUserError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
@@ -0,0 +1,139 @@
+package setup_test
+
+import (
+	"bytes"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"send2slack/internal/config"
+	"send2slack/internal/setup"
+	"strings"
+	"testing"
+)
+
+func TestWizard_Prompt(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tcs := []struct {
+		name     string
+		server   bool
+		in       string
+		expected setup.Answers
+	}{
+		{
+			name:   "client with token",
+			server: false,
+			in:     "xoxb-1\nops\n\n",
+			expected: setup.Answers{
+				Token:          "xoxb-1",
+				DefaultChannel: "ops",
+				EmailChannel:   "ops",
+				RemoteUrl:      "false",
+				ListenUrl:      "127.0.0.1:4789",
+				MboxWatch:      "false",
+			},
+		},
+		{
+			name:   "client using a server",
+			server: false,
+			in:     "\nunix:///run/send2slack.sock\n\nmails\n",
+			expected: setup.Answers{
+				DefaultChannel: "general",
+				EmailChannel:   "mails",
+				RemoteUrl:      "unix:///run/send2slack.sock",
+				ListenUrl:      "127.0.0.1:4789",
+				MboxWatch:      "false",
+			},
+		},
+		{
+			name:   "server asks again for invalid values",
+			server: true,
+			in:     "xoxb-1\n\n\nlocalhost:http\n:4790\n/nonexistent\n" + dir + "\n",
+			expected: setup.Answers{
+				Token:          "xoxb-1",
+				DefaultChannel: "general",
+				EmailChannel:   "general",
+				RemoteUrl:      "false",
+				ListenUrl:      ":4790",
+				MboxWatch:      dir,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := setup.NewWizard(strings.NewReader(tc.in), &out).Prompt(tc.server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, *got); diff != "" {
+				t.Errorf("unexpected answers (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("input closed", func(t *testing.T) {
+		_, err := setup.NewWizard(strings.NewReader("xoxb-1\n"), ioutil.Discard).Prompt(true)
+		if err == nil {
+			t.Error("expected an error if the input ends")
+		}
+	})
+}
+
+func TestRenderAndWrite(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "s2s_setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, server := range []bool{false, true} {
+		a := setup.DefaultAnswers()
+		a.Token = `xoxb-"quoted"`
+
+		content, err := setup.Render(server, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := dir + "/config.yaml"
+		err = setup.Write(file, content, true, "nonexistent-user")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("expected mode 0600 for a file with token, got %o", info.Mode().Perm())
+		}
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 1 {
+			t.Errorf("expected only the configuration in the directory, got %d files", len(files))
+		}
+
+		unknown, err := config.UnknownKeys(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(unknown) != 0 {
+			t.Errorf("unexpected unknown keys: %v", unknown)
+		}
+
+		cfg, err := config.NewClientConfig(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Token != a.Token || cfg.DefChannel != "general" {
+			t.Errorf("unexpected values read back, token %s channel %s", cfg.Token, cfg.DefChannel)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package setup
+
+// the templates follow the sample configurations in resources/config, the optional settings are commented
+
+const slackSection = `## slack specific configuration
+slack:
+  ## the token used to send messages, the env variable SLACK_TOKEN overwrites it
+  token: {{ quote .Token }}
+  ## read the token from a file or from the output of a command instead, i.e. "pass show slack"
+  ## precedence: $SLACK_TOKEN, the systemd credential "slack_token", token_command, token_file, token
+  #token_file: "/etc/send2slack/token"
+  #token_command: "pass show slack"
+  ## the default channel if none are specified
+  default_channel: {{ quote .DefaultChannel }}
+  ## the default channel to deliver mails to, used if not defined with header in email
+  email_channel: {{ quote .EmailChannel }}
+  ## messages that cannot be delivered, i.e. the channel does not exist, are sent to this channel instead
+  #fallback_channel: "general"
+  ## long messages are truncated, upload the complete text as file in the thread (needs the scope files:write)
+  #upload_overflow: true
+
+  ## messages with a severity (info, warning, error, critical) are prefixed with an emoji and colored,
+  ## every severity can be sent to a different channel and notify "here", "channel" or a user group id
+  #severity:
+  #  critical:
+  #    channel: "ops"
+  #    color: "red"
+  #    emoji: ":rotating_light:"
+  #    mention: "here"
+`
+
+const clientTemplate = `---
+` + slackSection + `
+client:
+  ## send messages to a http send2slack service, instead of using the token directly
+  ## a unix socket is set as unix:///run/send2slack.sock, use string false to disable
+  remote_url: {{ quote .RemoteUrl }}
+
+  ## https connection to the server, the system roots are used if no ca is set
+  ## cert and key are only needed if the server requires client certificates
+  #tls:
+  #  ca: "/etc/send2slack/ca.pem"
+  #  cert: "/etc/send2slack/client.pem"
+  #  key: "/etc/send2slack/client-key.pem"
+`
+
+const serverTemplate = `---
+## the *.yaml files in conf.d next to this file are merged on top of it in lexical order
+
+` + slackSection + `
+daemon:
+  ## bind address for the server, i.e :<port> or <ip>:<port> 127.0.0.1:4789
+  ## or a unix socket, i.e. unix:///run/send2slack.sock, use string false to disable
+  listen_url: {{ quote .ListenUrl }}
+
+  ## ownership and permissions of the unix socket, the mode defaults to 0660
+  #socket:
+  #  owner: "root"
+  #  group: "send2slack"
+  #  mode: "0660"
+
+  ## serve https, the certificate is reloaded when the files change
+  ## with client_ca set, clients need a certificate signed by this CA (mTLS)
+  #tls:
+  #  cert: "/etc/send2slack/server.pem"
+  #  key: "/etc/send2slack/server-key.pem"
+  #  client_ca: "/etc/send2slack/ca.pem"
+
+  ## relay mode, forward all messages to an upstream send2slack server instead of slack, no token is needed
+  ## messages are spooled while the upstream server is not reachable
+  #relay:
+  #  url: "https://send2slack.example.com:4789"
+  #  spool: "/var/spool/send2slack"
+
+  ## path for the mbox to watch, i.e. /var/mail, use string false to disable
+  mbox_watch: {{ quote .MboxWatch }}
+
+  ## route the mails of a local unix user (the mbox file name) to a slack direct message
+  ## destinations are either "@<slack handle>" or "user:<email>"
+  #mbox_users:
+  #  alice: "user:alice@example.com"
+  #  bob: "@bob"
+
+  ## webhooks, syslog, log files and smtp are configured with the sections hooks, github, gitlab, syslog,
+  ## logwatch and smtp, see resources/config/server.yaml for samples
+`
@@ -0,0 +1,130 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+	"io"
+	"send2slack/internal/sender"
+	"strings"
+	"time"
+)
+
+// minimum time between two messages, slack rate limits message updates to about one per second
+const MinInterval = time.Second
+
+// amount of lines shown in an updated message, older lines are dropped
+const maxUpdateLines = 40
+
+// maximum length of a single line read from the input
+const maxLineSize = 1 << 20
+
+// Streamer sends the lines read from an input while the input is still being written, the lines are
+// batched and sent at most once per interval
+type Streamer struct {
+	MsgSender   sender.MessageSender
+	Destination string
+	Color       string
+	Thread      bool // post new lines as replies in the thread instead of updating the message
+	Interval    time.Duration
+	ThreadKey   string // identifies the message that is updated or the thread the lines are posted to
+}
+
+// Stream reads the input until EOF, the first batch of lines is posted as message, later batches
+// update the message or are posted as thread replies. if slack rate limits the messages the lines are kept
+// and sent once slack accepts messages again, batches failing for other reasons are skipped and the first
+// of these errors is returned once the input is read completely
+func (s *Streamer) Stream(r io.Reader) error {
+
+	interval := s.Interval
+	if interval < MinInterval {
+		interval = MinInterval
+	}
+
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		readErr <- scanner.Err()
+		close(lines)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var shown []string    // lines of the updated message
+	var pending []string  // lines not sent yet
+	var retryAt time.Time // slack asked to not send messages before
+	var sendErr error     // first error of a skipped batch
+	posted := false
+
+	send := func() {
+		if len(pending) == 0 || time.Now().Before(retryAt) {
+			return
+		}
+		msg := sender.Message{
+			Destination: s.Destination,
+			Color:       s.Color,
+			ThreadKey:   s.ThreadKey,
+		}
+		updated := append(append([]string{}, shown...), pending...)
+		if len(updated) > maxUpdateLines {
+			updated = updated[len(updated)-maxUpdateLines:]
+		}
+		if s.Thread {
+			msg.Text = codeBlock(pending)
+		} else {
+			msg.Text = codeBlock(updated)
+			msg.Update = posted
+		}
+
+		err := s.MsgSender.SendMessage(&msg)
+		var rateErr *slack.RateLimitedError
+		if errors.As(err, &rateErr) {
+			retryAt = time.Now().Add(rateErr.RetryAfter)
+			log.Warnf("rate limited by slack, sending %d lines again in %s", len(pending), rateErr.RetryAfter)
+			return
+		}
+		if err != nil {
+			log.Warnf("skipping %d lines: %v", len(pending), err)
+			if sendErr == nil {
+				sendErr = err
+			}
+		} else {
+			posted = true
+		}
+		// skipped lines are still shown in the next update of the message
+		shown = updated
+		pending = nil
+	}
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				for len(pending) > 0 {
+					time.Sleep(time.Until(retryAt))
+					send()
+				}
+				err := <-readErr
+				if err != nil {
+					return err
+				}
+				return sendErr
+			}
+			pending = append(pending, line)
+
+		case <-ticker.C:
+			send()
+		}
+	}
+}
+
+func codeBlock(lines []string) string {
+	return "```" + strings.Join(lines, "\n") + "```"
+}
@@ -0,0 +1,143 @@
+package stream_test
+
+import (
+	"errors"
+	"github.com/slack-go/slack"
+	"io"
+	"send2slack/internal/sender"
+	"send2slack/internal/stream"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSender keeps all the messages sent, the first messages fail with errs
+type recordSender struct {
+	mutex sync.Mutex
+	msgs  []sender.Message
+	errs  []error
+}
+
+func (s *recordSender) SendMessage(msg *sender.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	s.msgs = append(s.msgs, *msg)
+	return nil
+}
+
+func (s *recordSender) SendError(err error) {}
+
+func (s *recordSender) sent() []sender.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]sender.Message{}, s.msgs...)
+}
+
+// streamLines writes two batches of lines with a pause longer than the interval in between
+func streamLines(t *testing.T, thread bool) []sender.Message {
+	msgs, err := streamLinesErrs(thread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+// streamLinesErrs streams the two batches of streamLines, the first messages fail with errs
+func streamLinesErrs(thread bool, errs ...error) ([]sender.Message, error) {
+	r, w := io.Pipe()
+	rec := &recordSender{errs: errs}
+	streamer := stream.Streamer{
+		MsgSender:   rec,
+		Destination: "builds",
+		Thread:      thread,
+		Interval:    stream.MinInterval,
+		ThreadKey:   "stream:test",
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- streamer.Stream(r)
+	}()
+
+	io.WriteString(w, "line1\nline2\n")
+	time.Sleep(stream.MinInterval + 500*time.Millisecond)
+	io.WriteString(w, "line3\n")
+	w.Close()
+
+	err := <-done
+	return rec.sent(), err
+}
+
+func TestStreamer_Update(t *testing.T) {
+	msgs := streamLines(t, false)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Update || msgs[0].Text != "```line1\nline2```" {
+		t.Errorf("unexpected first message: %+v", msgs[0])
+	}
+	if !msgs[1].Update || msgs[1].Text != "```line1\nline2\nline3```" {
+		t.Errorf("expected the message to be updated with all lines, got: %+v", msgs[1])
+	}
+	for _, m := range msgs {
+		if m.ThreadKey != "stream:test" || m.Destination != "builds" {
+			t.Errorf("unexpected thread key or destination: %+v", m)
+		}
+	}
+}
+
+func TestStreamer_Thread(t *testing.T) {
+	msgs := streamLines(t, true)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Text != "```line1\nline2```" {
+		t.Errorf("unexpected first message: %s", msgs[0].Text)
+	}
+	if msgs[1].Update || msgs[1].Text != "```line3```" {
+		t.Errorf("expected a reply with the new lines, got: %+v", msgs[1])
+	}
+}
+
+func TestStreamer_Empty(t *testing.T) {
+	rec := &recordSender{}
+	streamer := stream.Streamer{MsgSender: rec}
+	err := streamer.Stream(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.sent()) != 0 {
+		t.Errorf("expected no message for an empty input")
+	}
+}
+
+func TestStreamer_RateLimited(t *testing.T) {
+	rec := &recordSender{errs: []error{&slack.RateLimitedError{RetryAfter: 200 * time.Millisecond}}}
+	streamer := stream.Streamer{MsgSender: rec, ThreadKey: "stream:test"}
+
+	err := streamer.Stream(strings.NewReader("line1\nline2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := rec.sent()
+	if len(msgs) != 1 || msgs[0].Text != "```line1\nline2```" {
+		t.Errorf("expected the lines to be sent after the rate limit, got: %+v", msgs)
+	}
+}
+
+func TestStreamer_SendError(t *testing.T) {
+	msgs, err := streamLinesErrs(true, errors.New("channel_not_found"))
+	if err == nil || err.Error() != "channel_not_found" {
+		t.Errorf("expected the error of the skipped batch, got: %v", err)
+	}
+	// the input is read until the end and the later lines are sent
+	if len(msgs) != 1 || msgs[0].Text != "```line3```" {
+		t.Errorf("expected the second batch to be sent, got: %+v", msgs)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+// +build !unix
+
+package wrapper
+
+import (
+	"os/exec"
+)
+
+// exitStatus returns the exit code of the command, signals are only reported on unix
+func exitStatus(exitErr *exec.ExitError) (int, string) {
+	return exitErr.ExitCode(), ""
+}
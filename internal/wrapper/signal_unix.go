@@ -0,0 +1,19 @@
+//go:build unix
+// +build unix
+
+package wrapper
+
+import (
+	"golang.org/x/sys/unix"
+	"os/exec"
+	"syscall"
+)
+
+// exitStatus returns the exit code and the name of the signal that killed the command, killed commands
+// exit with 128 plus the signal number like in the shell
+func exitStatus(exitErr *exec.ExitError) (int, string) {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return exitSignaled + int(status.Signal()), unix.SignalName(status.Signal())
+	}
+	return exitErr.ExitCode(), ""
+}
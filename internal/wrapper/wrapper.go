@@ -0,0 +1,110 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exit code reported if the command could not be started, same as the shell uses for unknown commands
+const ExitNotStarted = 127
+
+// exit code base of commands killed by a signal, the signal number is added like the shell does
+const exitSignaled = 128
+
+// Result of a command execution
+type Result struct {
+	ExitCode int
+	Duration time.Duration
+	Output   string // last lines of stdout and stderr
+	Signal   string // name of the signal that killed the command
+	Err      error  // set if the command could not be started
+}
+
+// Run executes the command, stdout and stderr are passed through and the last tailLines lines of both are
+// kept in the result in the order they arrive
+func Run(name string, args []string, tailLines int) *Result {
+
+	tail := newTailBuffer(tailLines)
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+
+	start := time.Now()
+	err := cmd.Run()
+	res := Result{
+		Duration: time.Since(start),
+		Output:   tail.String(),
+	}
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			res.ExitCode, res.Signal = exitStatus(exitErr)
+		} else {
+			res.ExitCode = ExitNotStarted
+			res.Err = err
+		}
+	}
+	return &res
+}
+
+// CommandLine returns the command as it would be typed in a shell, used in the messages
+func CommandLine(name string, args []string) string {
+	parts := []string{name}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'$`\\") {
+			a = fmt.Sprintf("%q", a)
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
+
+// tailBuffer keeps the last lines written to it
+type tailBuffer struct {
+	mutex   sync.Mutex
+	max     int
+	lines   []string
+	partial string // last line not terminated by a new line
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	if t.max <= 0 {
+		return len(p), nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	lines := strings.Split(t.partial+string(p), "\n")
+	t.partial = lines[len(lines)-1]
+	t.lines = append(t.lines, lines[:len(lines)-1]...)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	lines := append([]string{}, t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	if len(lines) > t.max {
+		lines = lines[len(lines)-t.max:]
+	}
+	return strings.Join(lines, "\n")
+}
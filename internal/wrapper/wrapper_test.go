@@ -0,0 +1,86 @@
+package wrapper_test
+
+import (
+	"send2slack/internal/wrapper"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+
+	tcs := []struct {
+		name         string
+		script       string
+		tail         int
+		expectedCode int
+		expectedOut  string
+	}{
+		{
+			name:         "success",
+			script:       "echo line1; echo line2",
+			tail:         10,
+			expectedCode: 0,
+			expectedOut:  "line1\nline2",
+		},
+		{
+			name:         "failure with stderr",
+			script:       "echo started; sleep 0.1; echo failed >&2; exit 3",
+			tail:         10,
+			expectedCode: 3,
+			expectedOut:  "started\nfailed",
+		},
+		{
+			name:         "tail lines",
+			script:       "for i in 1 2 3 4 5; do echo $i; done; printf last",
+			tail:         3,
+			expectedCode: 0,
+			expectedOut:  "4\n5\nlast",
+		},
+		{
+			name:         "no output kept",
+			script:       "echo hidden",
+			tail:         0,
+			expectedCode: 0,
+			expectedOut:  "",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			res := wrapper.Run("sh", []string{"-c", tc.script}, tc.tail)
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if res.ExitCode != tc.expectedCode {
+				t.Errorf("unexpected exit code, got %d expected %d", res.ExitCode, tc.expectedCode)
+			}
+			if res.Output != tc.expectedOut {
+				t.Errorf("unexpected output, got \"%s\" expected \"%s\"", res.Output, tc.expectedOut)
+			}
+		})
+	}
+
+	t.Run("killed by signal", func(t *testing.T) {
+		res := wrapper.Run("sh", []string{"-c", "echo started; kill -TERM $$"}, 10)
+		if res.ExitCode != 143 || res.Signal != "SIGTERM" {
+			t.Errorf("expected exit code 143 and SIGTERM, got %d and \"%s\"", res.ExitCode, res.Signal)
+		}
+		if res.Output != "started" {
+			t.Errorf("unexpected output, got \"%s\"", res.Output)
+		}
+	})
+
+	t.Run("command not found", func(t *testing.T) {
+		res := wrapper.Run("/nonexistent/command", nil, 10)
+		if res.Err == nil || res.ExitCode != wrapper.ExitNotStarted {
+			t.Errorf("expected the command not to start, got exit code %d and error %v", res.ExitCode, res.Err)
+		}
+	})
+}
+
+func TestCommandLine(t *testing.T) {
+	got := wrapper.CommandLine("./backup.sh", []string{"--target", "/mnt/backup dir", ""})
+	expected := `./backup.sh --target "/mnt/backup dir" ""`
+	if got != expected {
+		t.Errorf("unexpected command line, got %s expected %s", got, expected)
+	}
+}
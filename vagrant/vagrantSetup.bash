@@ -18,8 +18,8 @@ sudo apt-get install joe curl wget
 
 cd /home/vagrant
 rm -rf /usr/local/go/
-wget https://dl.google.com/go/go1.13.3.linux-amd64.tar.gz
-tar -xvf go1.13.3.linux-amd64.tar.gz
+wget https://dl.google.com/go/go1.22.12.linux-amd64.tar.gz
+tar -xvf go1.22.12.linux-amd64.tar.gz
 sudo mv go /usr/local
 
 ## goreleaser